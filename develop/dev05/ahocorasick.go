@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"unicode"
+)
+
+/*
+Алгоритм Ахо-Корасик для поиска большого списка фиксированных строк (-F -f patterns.txt).
+
+Альтернатива из тысяч строк "a|b|c|..." компилируется долго и ищет медленно,
+а автомат Ахо-Корасик находит все вхождения всех шаблонов за один проход по строке:
+время поиска линейно по длине строки плюс количество найденных вхождений.
+
+Автомат строится по рунам, а не по байтам, чтобы -i корректно работал с кириллицей:
+каждая руна приводится к нижнему регистру, а смещения совпадений считаются в байтах исходной строки.
+*/
+
+// acNode - вершина бора
+type acNode struct {
+	next map[rune]int
+	// fail - суффиксная ссылка: самый длинный собственный суффикс текущего префикса, который есть в боре
+	fail int
+	// output - длины (в рунах) шаблонов, которые заканчиваются в этой вершине, включая найденные по суффиксным ссылкам
+	output []int
+}
+
+type ahoCorasick struct {
+	nodes      []acNode
+	ignoreCase bool
+}
+
+func newAhoCorasick(patterns []string, ignoreCase bool) *ahoCorasick {
+	ac := &ahoCorasick{
+		nodes:      []acNode{{next: make(map[rune]int)}},
+		ignoreCase: ignoreCase,
+	}
+	// Строим бор из всех шаблонов
+	for _, pattern := range patterns {
+		cur := 0
+		length := 0
+		for _, r := range pattern {
+			r = ac.fold(r)
+			nxt, ok := ac.nodes[cur].next[r]
+			if !ok {
+				nxt = len(ac.nodes)
+				ac.nodes = append(ac.nodes, acNode{next: make(map[rune]int)})
+				ac.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+			length++
+		}
+		ac.nodes[cur].output = append(ac.nodes[cur].output, length)
+	}
+
+	// Обходом в ширину проставляем суффиксные ссылки. Вершины одного уровня обрабатываются после
+	// всех вершин меньшей глубины, поэтому ссылка родителя к моменту обработки ребенка уже готова
+	queue := make([]int, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.nodes[cur].next {
+			queue = append(queue, child)
+			ac.nodes[child].fail = ac.step(ac.nodes[cur].fail, r)
+			// Все шаблоны, оканчивающиеся в суффиксе, оканчиваются и в текущей вершине
+			ac.nodes[child].output = append(ac.nodes[child].output, ac.nodes[ac.nodes[child].fail].output...)
+		}
+	}
+	return ac
+}
+
+func (ac *ahoCorasick) fold(r rune) rune {
+	if ac.ignoreCase {
+		return unicode.ToLower(r)
+	}
+	return r
+}
+
+func (ac *ahoCorasick) step(state int, r rune) int {
+	// step выполняет переход автомата по руне r, при необходимости откатываясь по суффиксным ссылкам
+	for {
+		if nxt, ok := ac.nodes[state].next[r]; ok {
+			return nxt
+		}
+		if state == 0 {
+			return 0
+		}
+		state = ac.nodes[state].fail
+	}
+}
+
+// MatchString сообщает, содержит ли s хотя бы один из шаблонов
+func (ac *ahoCorasick) MatchString(s string) bool {
+	state := 0
+	for _, r := range s {
+		state = ac.step(state, ac.fold(r))
+		if len(ac.nodes[state].output) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FindAllStringIndex возвращает байтовые пары [начало, конец) непересекающихся вхождений шаблонов,
+// как одноименный метод regexp.Regexp. Из пересекающихся вхождений выбирается самое левое,
+// а среди начинающихся в одной позиции - самое длинное, как это делает grep -F.
+// n < 0 означает "все вхождения"
+func (ac *ahoCorasick) FindAllStringIndex(s string, n int) [][]int {
+	// offsets[i] - байтовое смещение i-й руны, последний элемент - len(s)
+	offsets := make([]int, 0, len(s)+1)
+	var candidates [][]int
+	state := 0
+	for i, r := range s {
+		offsets = append(offsets, i)
+		state = ac.step(state, ac.fold(r))
+		end := len(offsets)
+		for _, length := range ac.nodes[state].output {
+			candidates = append(candidates, []int{end - length, end})
+		}
+	}
+	offsets = append(offsets, len(s))
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i][0] != candidates[j][0] {
+			return candidates[i][0] < candidates[j][0]
+		}
+		return candidates[i][1] > candidates[j][1]
+	})
+	var result [][]int
+	pos := 0
+	for _, c := range candidates {
+		if n >= 0 && len(result) == n {
+			break
+		}
+		// Пустые шаблоны и вхождения, пересекающиеся с уже выбранными, пропускаем
+		if c[0] < pos || c[0] == c[1] {
+			continue
+		}
+		result = append(result, []int{offsets[c[0]], offsets[c[1]]})
+		pos = c[1]
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		ignoreCase bool
+		line       string
+		expected   [][]int
+	}{
+		{"нет совпадений", []string{"he", "she"}, false, "abc", nil},
+		{"пересекающиеся шаблоны", []string{"he", "she", "hers"}, false, "ushers", [][]int{{1, 4}}},
+		{"самое длинное в одной позиции", []string{"a", "ab", "abc"}, false, "abcab", [][]int{{0, 3}, {3, 5}}},
+		{"суффиксные ссылки", []string{"abcd", "bc"}, false, "abce", [][]int{{1, 3}}},
+		{"кириллица без учета регистра", []string{"ошибка"}, true, "ОШИБКА и Ошибка", [][]int{{0, 12}, {16, 28}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ac := newAhoCorasick(test.patterns, test.ignoreCase)
+			result := ac.FindAllStringIndex(test.line, -1)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+			if ac.MatchString(test.line) != (test.expected != nil) {
+				t.Errorf("MatchString: ожидалось: %v", test.expected != nil)
+			}
+			if len(test.expected) > 0 {
+				first := ac.FindAllStringIndex(test.line, 1)
+				if !reflect.DeepEqual(first, test.expected[:1]) {
+					t.Errorf("Ожидалось: %v, получено: %v", test.expected[:1], first)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAhoCorasick(b *testing.B) {
+	// Сравнение с альтернативой из тысячи фиксированных строк
+	var patterns []string
+	for i := 0; i < 1000; i++ {
+		patterns = append(patterns, "token"+strings.Repeat("x", i%7)+string(rune('a'+i%26))+string(rune('a'+i/26%26)))
+	}
+	line := strings.Repeat("обычная строка лога без совпадений ", 10) + patterns[999]
+
+	b.Run("ahoCorasick", func(b *testing.B) {
+		ac := newAhoCorasick(patterns, false)
+		for i := 0; i < b.N; i++ {
+			ac.MatchString(line)
+		}
+	})
+	b.Run("regexp", func(b *testing.B) {
+		reg, err := createRegexp(&grepConfig{fixed: true}, patterns...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < b.N; i++ {
+			reg.MatchString(line)
+		}
+	})
+}
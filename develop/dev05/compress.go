@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+Поиск внутри сжатых файлов (-z), как zgrep или rg -z.
+
+Формат определяется не по расширению, а по первым байтам файла (magic bytes),
+поэтому ротированные логи вида app.log.1 без расширения .gz тоже распознаются.
+Распаковка идет потоково, на лету, а несжатые файлы читаются как обычно.
+*/
+
+// Сигнатуры поддерживаемых форматов сжатия
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// zstdReader закрывает декодер zstd, который держит свои горутины до вызова Close
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (z zstdReader) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+func decompress(r *bufio.Reader) (io.ReadCloser, error) {
+	// decompress смотрит на первые байты r и при необходимости оборачивает его распаковщиком.
+	// Peek не сдвигает позицию чтения, поэтому несжатый файл читается с начала
+	head, _ := r.Peek(len(magicZstd))
+	switch {
+	case bytes.HasPrefix(head, magicGzip):
+		return gzip.NewReader(r)
+	case bytes.HasPrefix(head, magicBzip2):
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case bytes.HasPrefix(head, magicZstd):
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zstdReader{decoder}, nil
+	}
+	return io.NopCloser(r), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const compressContent = "первая строка\nERROR внутри архива\nпоследняя строка\n"
+
+// Вспомогательная функция: сжимает содержимое и записывает во временный файл
+func createCompressedFile(t *testing.T, name string, compress func(*bytes.Buffer) error) string {
+	var buf bytes.Buffer
+	if err := compress(&buf); err != nil {
+		t.Fatalf("Не удалось сжать данные: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("Не удалось создать файл: %v", err)
+	}
+	return path
+}
+
+func TestSearchCompressed(t *testing.T) {
+	gzipFile := createCompressedFile(t, "app.log.gz", func(buf *bytes.Buffer) error {
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write([]byte(compressContent)); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	// Формат определяется по содержимому, а не по расширению
+	zstdFile := createCompressedFile(t, "app.log.1", func(buf *bytes.Buffer) error {
+		w, err := zstd.NewWriter(buf)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(compressContent)); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	// В стандартной библиотеке нет упаковщика bzip2, поэтому файл заранее создан утилитой bzip2
+	bzip2File := filepath.Join("testdata", "rotated.log.bz2")
+	plainFile := createCompressedFile(t, "plain.log", func(buf *bytes.Buffer) error {
+		_, err := buf.WriteString(compressContent)
+		return err
+	})
+
+	reg := regexp.MustCompile("ERROR")
+	tests := []struct {
+		name     string
+		file     string
+		expected string
+	}{
+		{"gzip", gzipFile, gzipFile + ":2:ERROR внутри архива\n"},
+		{"zstd", zstdFile, zstdFile + ":2:ERROR внутри архива\n"},
+		{"bzip2", bzip2File, bzip2File + ":2:ERROR внутри bzip2\n"},
+		{"несжатый файл", plainFile, plainFile + ":2:ERROR внутри архива\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := searchToBuffer(&grepConfig{searchZip: true, lineNum: true}, reg, test.file, true)
+			if result.err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", result.err)
+			}
+			if string(result.output) != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result.output)
+			}
+		})
+	}
+
+	// Без -z сжатый файл - это просто двоичные данные, строки из него не печатаются
+	result := searchToBuffer(&grepConfig{}, reg, gzipFile, false)
+	if bytes.Contains(result.output, []byte("внутри архива")) {
+		t.Errorf("Ожидался поиск без распаковки, получено: %q", result.output)
+	}
+
+	// Поврежденный архив - ошибка
+	broken := createCompressedFile(t, "broken.gz", func(buf *bytes.Buffer) error {
+		_, err := buf.Write(append(append([]byte{}, magicGzip...), 0xff, 0xff))
+		return err
+	})
+	if result := searchToBuffer(&grepConfig{searchZip: true}, reg, broken, false); result.err == nil {
+		t.Errorf("Ожидалась ошибка для поврежденного архива")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+Учет .gitignore при рекурсивном поиске (-r), как в ripgrep.
+
+Поддерживается основной синтаксис gitignore:
+- пустые строки и строки с # пропускаются;
+- шаблон без "/" совпадает с именем на любом уровне вложенности (*.log);
+- шаблон со "/" в начале или в середине привязан к директории, где лежит .gitignore (/build, docs/*.md);
+- "/" в конце - только для директорий (tmp/);
+- "**" отдельным сегментом пути - любое количество вложенных директорий;
+- "!" в начале отменяет игнорирование; побеждает последнее подходящее правило.
+*/
+
+// ignoreRule - одно правило из файла .gitignore
+type ignoreRule struct {
+	// base - директория, в которой лежит .gitignore
+	base     string
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+func parseGitignore(base string, lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		// "\#" и "\!" экранируют служебные символы в начале строки
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func loadGitignore(dir string) ([]ignoreRule, error) {
+	// loadGitignore читает правила из dir/.gitignore. Отсутствие файла - не ошибка
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return parseGitignore(dir, lines), scanner.Err()
+}
+
+func isIgnored(rules []ignoreRule, filePath string, isDir bool) bool {
+	// isIgnored проверяет путь по правилам всех .gitignore от корня обхода до родительской директории
+	ignored := false
+	for _, rule := range rules {
+		rel, err := filepath.Rel(rule.base, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+		if matchGlobPath(rule.pattern, target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func matchGlobPath(pattern, name string) bool {
+	// matchGlobPath сравнивает путь с шаблоном по сегментам: "**" совпадает с любым количеством сегментов,
+	// остальные сегменты сравниваются через path.Match
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Пробуем отдать "**" от нуля до всех оставшихся сегментов
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
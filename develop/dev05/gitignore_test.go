@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsIgnored(t *testing.T) {
+	rules := parseGitignore("repo", []string{
+		"# комментарий",
+		"*.log",
+		"!important.log",
+		"/build",
+		"tmp/",
+		"docs/**/draft.md",
+		"",
+	})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"repo/app.log", false, true},
+		{"repo/sub/deep/app.log", false, true},
+		{"repo/important.log", false, false},
+		{"repo/build", true, true},
+		{"repo/src/build", true, false},
+		{"repo/tmp", true, true},
+		{"repo/tmp", false, false},
+		{"repo/docs/draft.md", false, true},
+		{"repo/docs/a/b/draft.md", false, true},
+		{"repo/main.go", false, false},
+		{"other/app.log", false, false},
+	}
+	for _, test := range tests {
+		if result := isIgnored(rules, test.path, test.isDir); result != test.expected {
+			t.Errorf("%s: ожидалось: %v, получено: %v", test.path, test.expected, result)
+		}
+	}
+}
+
+func TestCollectInputsGitignore(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":      "*.log\nvendor/\n",
+		"main.go":         "package main",
+		"app.log":         "лог",
+		"vendor/lib.go":   "package lib",
+		"sub/.gitignore":  "!keep.log\ngen.go\n",
+		"sub/keep.log":    "лог",
+		"sub/gen.go":      "package sub",
+		"sub/sub.go":      "package sub",
+		".git/HEAD":       "ref: refs/heads/main",
+		"other/other.log": "лог",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inputs, errs := collectInputs(&grepConfig{recursive: true}, []string{dir})
+	if len(errs) != 0 {
+		t.Fatalf("Неожиданные ошибки: %v", errs)
+	}
+	expected := []string{
+		filepath.Join(dir, ".gitignore"),
+		filepath.Join(dir, "main.go"),
+		filepath.Join(dir, "sub/.gitignore"),
+		filepath.Join(dir, "sub/keep.log"),
+		filepath.Join(dir, "sub/sub.go"),
+	}
+	if !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, inputs)
+	}
+
+	// С --no-ignore обходятся все файлы
+	inputs, _ = collectInputs(&grepConfig{recursive: true, noIgnore: true}, []string{dir})
+	if len(inputs) != len(files) {
+		t.Errorf("Ожидалось файлов: %v, получено: %v", len(files), inputs)
+	}
+}
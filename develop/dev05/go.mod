@@ -1,3 +1,5 @@
 module dev05
 
 go 1.22.3
+
+require github.com/klauspost/compress v1.18.0
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+Вывод результатов в формате JSON Lines (--json), по образцу ripgrep.
+
+Каждая строка вывода - отдельный объект {"type": ..., "data": ...}:
+- begin   - начало совпадений в файле;
+- match   - выбранная строка с номером, смещением в байтах и границами всех совпадений;
+- context - строка контекста (-A, -B, -C);
+- end     - конец файла со статистикой по нему;
+- summary - итог по всем файлам, печатается последним.
+
+События begin и end печатаются только для файлов, в которых что-то нашлось.
+Текст, который не является корректным UTF-8, передается в поле "bytes" в base64, а не в "text".
+*/
+
+// jsonText - строка или байты в base64, если строка не является корректным UTF-8
+type jsonText struct {
+	Text  string `json:"text,omitempty"`
+	Bytes string `json:"bytes,omitempty"`
+}
+
+func newJSONText(s string) jsonText {
+	if utf8.ValidString(s) {
+		return jsonText{Text: s}
+	}
+	return jsonText{Bytes: base64.StdEncoding.EncodeToString([]byte(s))}
+}
+
+type jsonEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+}
+
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonFileStats struct {
+	BytesSearched int64 `json:"bytes_searched"`
+	MatchedLines  int   `json:"matched_lines"`
+	Matches       int   `json:"matches"`
+}
+
+type jsonEnd struct {
+	Path jsonText `json:"path"`
+	// BinaryOffset - смещение первого нулевого байта, если файл двоичный, иначе null
+	BinaryOffset *int64        `json:"binary_offset"`
+	Stats        jsonFileStats `json:"stats"`
+}
+
+type jsonDuration struct {
+	Secs  int64  `json:"secs"`
+	Nanos int    `json:"nanos"`
+	Human string `json:"human"`
+}
+
+type jsonSummaryStats struct {
+	Searches          int `json:"searches"`
+	SearchesWithMatch int `json:"searches_with_match"`
+	MatchedLines      int `json:"matched_lines"`
+}
+
+type jsonSummary struct {
+	ElapsedTotal jsonDuration     `json:"elapsed_total"`
+	Stats        jsonSummaryStats `json:"stats"`
+}
+
+func writeJSON(w *bufio.Writer, eventType string, data interface{}) {
+	// Все поля - строки и числа, поэтому Marshal здесь не может вернуть ошибку
+	encoded, _ := json.Marshal(jsonEvent{Type: eventType, Data: data})
+	w.Write(encoded)
+	w.WriteByte('\n')
+}
+
+// jsonFile собирает события одного файла: begin перед первой строкой и статистику для end
+type jsonFile struct {
+	path  jsonText
+	begun bool
+	stats jsonFileStats
+}
+
+func newJSONFile(path string) *jsonFile {
+	return &jsonFile{path: newJSONText(path)}
+}
+
+func (jf *jsonFile) line(w *bufio.Writer, cfg *grepConfig, reg matcher, marker string, line contextLine) {
+	// line печатает событие match или context. Границы совпадений ищутся только в выбранных строках
+	if !jf.begun {
+		writeJSON(w, "begin", jsonBegin{Path: jf.path})
+		jf.begun = true
+	}
+	event := jsonLine{
+		Path:           jf.path,
+		Lines:          newJSONText(line.text + "\n"),
+		LineNumber:     line.num,
+		AbsoluteOffset: line.offset,
+		Submatches:     []jsonSubmatch{},
+	}
+	eventType := "context"
+	if marker == ":" {
+		eventType = "match"
+		jf.stats.MatchedLines++
+		if !cfg.invert {
+			for _, loc := range matchLocations(reg, line.text) {
+				event.Submatches = append(event.Submatches, jsonSubmatch{
+					Match: newJSONText(line.text[loc[0]:loc[1]]),
+					Start: loc[0],
+					End:   loc[1],
+				})
+			}
+			jf.stats.Matches += len(event.Submatches)
+		}
+	}
+	writeJSON(w, eventType, event)
+}
+
+func (jf *jsonFile) end(w *bufio.Writer, bytesSearched int64, binaryOffset *int64) {
+	// end печатает итог по файлу, если для него был begin
+	if !jf.begun {
+		return
+	}
+	jf.stats.BytesSearched = bytesSearched
+	writeJSON(w, "end", jsonEnd{Path: jf.path, BinaryOffset: binaryOffset, Stats: jf.stats})
+}
+
+func writeJSONBinary(w *bufio.Writer, path string, binaryOffset int64, selected int) {
+	// Для двоичного файла строки не печатаются, только begin и end со смещением нулевого байта
+	jf := newJSONFile(path)
+	writeJSON(w, "begin", jsonBegin{Path: jf.path})
+	jf.begun = true
+	jf.stats.MatchedLines = selected
+	jf.end(w, 0, &binaryOffset)
+}
+
+func writeJSONSummary(w *bufio.Writer, stats jsonSummaryStats, elapsed time.Duration) {
+	writeJSON(w, "summary", jsonSummary{
+		ElapsedTotal: jsonDuration{
+			Secs:  int64(elapsed / time.Second),
+			Nanos: int(elapsed % time.Second),
+			Human: elapsed.String(),
+		},
+		Stats: stats,
+	})
+}
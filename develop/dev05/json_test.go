@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONOutput(t *testing.T) {
+	fileName, err := createTestFile("a\nERROR x ERROR\nb\nc\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(fileName)
+	empty, err := createTestFile("ничего\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+
+	defer os.Remove(empty)
+
+	var out bytes.Buffer
+	cfg := &grepConfig{json: true, after: 1}
+	if code := run(cfg, []string{"ERROR", fileName, empty}, &out); code != exitMatch {
+		t.Fatalf("Ожидался код: %v, получено: %v", exitMatch, code)
+	}
+
+	var types []string
+	var events []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event struct {
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Некорректный JSON %q: %v", line, err)
+		}
+		types = append(types, event.Type)
+		events = append(events, event.Data)
+	}
+
+	// Для файла без совпадений begin и end не печатаются
+	expectedTypes := []string{"begin", "match", "context", "end", "summary"}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Fatalf("Ожидалось: %v, получено: %v", expectedTypes, types)
+	}
+
+	var match jsonLine
+	encoded, _ := json.Marshal(events[1])
+	if err := json.Unmarshal(encoded, &match); err != nil {
+		t.Fatal(err)
+	}
+	expectedMatch := jsonLine{
+		Path:           jsonText{Text: fileName},
+		Lines:          jsonText{Text: "ERROR x ERROR\n"},
+		LineNumber:     2,
+		AbsoluteOffset: 2,
+		Submatches: []jsonSubmatch{
+			{Match: jsonText{Text: "ERROR"}, Start: 0, End: 5},
+			{Match: jsonText{Text: "ERROR"}, Start: 8, End: 13},
+		},
+	}
+	if !reflect.DeepEqual(match, expectedMatch) {
+		t.Errorf("Ожидалось: %+v, получено: %+v", expectedMatch, match)
+	}
+
+	end := events[3]["stats"].(map[string]interface{})
+	if end["matched_lines"] != 1.0 || end["matches"] != 2.0 || end["bytes_searched"] != 20.0 {
+		t.Errorf("Неверная статистика файла: %v", end)
+	}
+	summary := events[4]["stats"].(map[string]interface{})
+	if summary["searches"] != 2.0 || summary["searches_with_match"] != 1.0 || summary["matched_lines"] != 1.0 {
+		t.Errorf("Неверная итоговая статистика: %v", summary)
+	}
+}
+
+func TestJSONText(t *testing.T) {
+	if text := newJSONText("строка"); text.Text != "строка" || text.Bytes != "" {
+		t.Errorf("Ожидался текст, получено: %+v", text)
+	}
+	// Некорректный UTF-8 передается в base64
+	if text := newJSONText("\xff\xfe"); text.Text != "" || text.Bytes != "//4=" {
+		t.Errorf("Ожидались байты в base64, получено: %+v", text)
+	}
+}
+
+func TestJSONIncompatibleFlags(t *testing.T) {
+	if code := run(&grepConfig{json: true, count: true}, []string{"a", "-"}, &bytes.Buffer{}); code != exitError {
+		t.Errorf("Ожидался код: %v, получено: %v", exitError, code)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+/*
+Параллельный поиск по нескольким файлам.
+
+Файлы ищутся пулом из cfg.workers горутин. Вывод отдается строго в порядке аргументов и обхода
+директорий, поэтому он не зависит от того, какой файл обработан раньше.
+
+Первый в очереди файл пишет вывод сразу в общий вывод, как при последовательном поиске.
+Файлы за ним копят вывод в памяти, пока до них не дойдет очередь: тогда накопленное сбрасывается,
+и дальше они тоже пишут напрямую. Одновременно в работе и в ожидании вывода находится
+не больше 2*workers файлов: если первый файл большой, остальные горутины не прочитают всё дерево
+в память, пока он ищется.
+*/
+
+// fileResult - результат поиска в одном файле
+type fileResult struct {
+	name     string
+	selected int
+	err      error
+}
+
+// fileOutput - вывод одного файла. Пока out == nil, файл не первый в очереди и вывод копится в buf
+type fileOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	out io.Writer
+}
+
+func (o *fileOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.out != nil {
+		return o.out.Write(p)
+	}
+	return o.buf.Write(p)
+}
+
+func (o *fileOutput) promote(out io.Writer) {
+	// promote делает файл первым в очереди: накопленный вывод уходит в out, дальнейший - сразу туда же
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out.Write(o.buf.Bytes())
+	o.buf = bytes.Buffer{}
+	o.out = out
+}
+
+func searchTo(w io.Writer, cfg *grepConfig, reg matcher, name string, withName bool) fileResult {
+	// searchTo ищет в файле так же, как grepInput, и пишет вывод в w
+	bw := bufio.NewWriter(w)
+	selected, err := grepInput(bw, cfg, reg, name, withName)
+	bw.Flush()
+	return fileResult{name: name, selected: selected, err: err}
+}
+
+func searchParallel(cfg *grepConfig, reg matcher, inputs []string, withName bool, out io.Writer, handle func(fileResult) bool) bool {
+	// searchParallel ищет в inputs параллельно и пишет вывод в out в порядке inputs.
+	// handle вызывается для каждого файла по порядку, когда его вывод уже записан. Если handle
+	// вернул true (например, первое совпадение с -q), поиск останавливается и searchParallel возвращает true
+	workers := max(cfg.workers, 1)
+	// slots[i] получает результат i-го файла. Буфер 1, чтобы горутина не ждала, пока до него дойдет очередь
+	slots := make([]chan fileResult, len(inputs))
+	outputs := make([]*fileOutput, len(inputs))
+	for i := range slots {
+		slots[i] = make(chan fileResult, 1)
+		outputs[i] = &fileOutput{}
+	}
+	// inFlight ограничивает количество файлов, которые ищутся или ждут вывода
+	inFlight := make(chan struct{}, 2*workers)
+	jobs := make(chan int)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(jobs)
+		for i := range inputs {
+			select {
+			case inFlight <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				slots[i] <- searchTo(outputs[i], cfg, reg, inputs[i], withName)
+			}
+		}()
+	}
+
+	for i := range inputs {
+		// Пока i-й файл первый, в out пишет только он, поэтому handle вызывается после его завершения
+		outputs[i].promote(out)
+		result := <-slots[i]
+		<-inFlight
+		if handle(result) {
+			return true
+		}
+	}
+	return false
+}
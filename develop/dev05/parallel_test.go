@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Вспомогательная функция: создает count файлов по lines строк, в каждой десятой строке есть "ERROR"
+func createTestTree(tb testing.TB, count, lines int) []string {
+	dir := tb.TempDir()
+	var names []string
+	for i := 0; i < count; i++ {
+		var sb strings.Builder
+		for j := 0; j < lines; j++ {
+			if j%10 == 0 {
+				fmt.Fprintf(&sb, "%d ERROR файл %d строка %d\n", j, i, j)
+			} else {
+				fmt.Fprintf(&sb, "%d INFO обычная строка лога с некоторым текстом\n", j)
+			}
+		}
+		name := filepath.Join(dir, fmt.Sprintf("file%03d.log", i))
+		if err := os.WriteFile(name, []byte(sb.String()), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// bufferedResult - результат поиска в одном файле вместе с его выводом
+type bufferedResult struct {
+	fileResult
+	output []byte
+}
+
+// Вспомогательная функция: поиск в одном файле с выводом в память
+func searchToBuffer(cfg *grepConfig, reg matcher, name string, withName bool) bufferedResult {
+	var buf bytes.Buffer
+	result := searchTo(&buf, cfg, reg, name, withName)
+	return bufferedResult{fileResult: result, output: buf.Bytes()}
+}
+
+func TestSearchParallelOrder(t *testing.T) {
+	names := createTestTree(t, 50, 100)
+	reg := regexp.MustCompile(`ERROR.*строка [15]0$`)
+
+	// Эталон - последовательный поиск
+	var expected bytes.Buffer
+	w := bufio.NewWriter(&expected)
+	for _, name := range names {
+		if _, err := grepInput(w, &grepConfig{}, reg, name, true); err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+	}
+	w.Flush()
+
+	for _, workers := range []int{1, 4, 16} {
+		var result bytes.Buffer
+		handled := 0
+		searchParallel(&grepConfig{workers: workers}, reg, names, true, &result, func(res fileResult) bool {
+			if res.name != names[handled] {
+				t.Errorf("workers=%d: ожидался файл %v, получен %v", workers, names[handled], res.name)
+			}
+			handled++
+			return false
+		})
+		if handled != len(names) {
+			t.Errorf("workers=%d: обработано %d файлов из %d", workers, handled, len(names))
+		}
+		if result.String() != expected.String() {
+			t.Errorf("workers=%d: вывод отличается от последовательного поиска", workers)
+		}
+	}
+}
+
+func TestSearchParallelStop(t *testing.T) {
+	names := createTestTree(t, 20, 10)
+	var out bytes.Buffer
+	var results []fileResult
+	stopped := searchParallel(&grepConfig{workers: 4}, regexp.MustCompile("ERROR"), names, false, &out, func(res fileResult) bool {
+		results = append(results, res)
+		return true
+	})
+	// Если handle вернул true, следующие файлы не обрабатываются
+	if !stopped || len(results) != 1 {
+		t.Fatalf("Поиск не остановился: получено %v результатов", len(results))
+	}
+	if results[0].name != names[0] || results[0].selected != 1 {
+		t.Errorf("Ожидался первый файл с 1 совпадением, получено: %v %v", results[0].name, results[0].selected)
+	}
+	if out.String() != "0 ERROR файл 0 строка 0\n" {
+		t.Errorf("Ожидался вывод только первого файла, получено: %q", out.String())
+	}
+}
+
+func TestBinaryFiles(t *testing.T) {
+	fileName, err := createTestFile("заголовок\x00\x01\x02\nшаблон\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(fileName)
+	reg := regexp.MustCompile("шаблон")
+
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		expected string
+	}{
+		{"двоичный файл", grepConfig{}, "Binary file " + fileName + " matches\n"},
+		{"флаг -a", grepConfig{text: true}, "шаблон\n"},
+		{"флаг -I", grepConfig{binaryWithoutMatch: true}, ""},
+		{"флаг -c считает строки", grepConfig{count: true}, "1\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := searchToBuffer(&test.cfg, reg, fileName, false)
+			if result.err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", result.err)
+			}
+			if string(result.output) != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result.output)
+			}
+		})
+	}
+}
+
+// Сравнение последовательного поиска (grepInput -> searchMatchLines по очереди) с пулом горутин
+func BenchmarkSearch(b *testing.B) {
+	names := createTestTree(b, 32, 2000)
+	reg := regexp.MustCompile(`ERROR.*строка \d+7\d$|обычная строка.*текстом`)
+
+	b.Run("sequential", func(b *testing.B) {
+		cfg := &grepConfig{count: true}
+		for i := 0; i < b.N; i++ {
+			for _, name := range names {
+				searchToBuffer(cfg, reg, name, true)
+			}
+		}
+	})
+	for _, workers := range []int{2, 4, 8} {
+		b.Run(fmt.Sprintf("parallel-%d", workers), func(b *testing.B) {
+			cfg := &grepConfig{count: true, workers: workers}
+			for i := 0; i < b.N; i++ {
+				searchParallel(cfg, reg, names, true, io.Discard, func(fileResult) bool { return false })
+			}
+		})
+	}
+}
+
+func TestFileOutputPromote(t *testing.T) {
+	// Вывод файла копится, пока файл не первый в очереди, а после promote идет сразу в общий вывод
+	var out bytes.Buffer
+	o := &fileOutput{}
+	o.Write([]byte("первая\n"))
+	if out.Len() != 0 {
+		t.Fatalf("Ожидался пустой вывод до promote, получено: %q", out.String())
+	}
+	o.promote(&out)
+	if out.String() != "первая\n" {
+		t.Errorf("Ожидалось: %q, получено: %q", "первая\n", out.String())
+	}
+	o.Write([]byte("вторая\n"))
+	if out.String() != "первая\nвторая\n" || o.buf.Len() != 0 {
+		t.Errorf("Ожидалась запись напрямую, получено: %q, в буфере %q", out.String(), o.buf.String())
+	}
+}
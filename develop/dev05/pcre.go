@@ -0,0 +1,824 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+Режим -P: регулярные выражения в стиле PCRE.
+
+RE2 из пакета regexp гарантирует линейное время поиска, но поэтому не поддерживает
+обратные ссылки (\1) и просмотр вперед/назад ((?=...), (?!...), (?<=...), (?<!...)).
+Здесь реализован небольшой движок с возвратами (backtracking), который их поддерживает.
+
+Поддерживается: литералы и экранирование, ., классы [...] и [^...], \d \w \s \D \W \S,
+\p{Name} и \P{Name}, якоря ^ $ \A \z \b \B, группы (...), (?:...), (?<name>...), (?P<name>...),
+атомарные группы (?>...), флаги (?i) и (?i:...), обратные ссылки \1..\9 и \k<name>,
+просмотр (?=...) (?!...) (?<=...) (?<!...), квантификаторы * + ? {n} {n,} {n,m}
+в жадной, ленивой (?) и сверхжадной (+) формах.
+
+Движок с возвратами на "катастрофических" шаблонах вроде (a+)+$ работает экспоненциально долго,
+поэтому поиск в каждой строке ограничен количеством шагов и временем. При превышении лимита
+строка считается несовпавшей, а grep сообщает об ошибке и завершается с кодом 2, как grep -P.
+*/
+
+// Ограничения движка по умолчанию на одну строку
+const (
+	defaultPCRESteps   = 1_000_000
+	defaultPCRETimeout = time.Second
+)
+
+var (
+	errPCRESteps   = errors.New("превышен лимит шагов поиска с возвратами (-P)")
+	errPCRETimeout = errors.New("превышен лимит времени поиска с возвратами (-P)")
+)
+
+// pcreNode - узел синтаксического дерева шаблона
+type pcreNode interface{}
+
+type (
+	pcreLiteral struct {
+		r    rune
+		fold bool
+	}
+	pcreAny   struct{}
+	pcreClass struct {
+		match  func(r rune) bool
+		negate bool
+		fold   bool
+	}
+	// pcreAssert - утверждение нулевой ширины: ^ $ \A \z \b \B
+	pcreAssert struct {
+		kind rune
+	}
+	pcreGroup struct {
+		index  int // 0 - незахватывающая группа
+		atomic bool
+		node   pcreNode
+	}
+	pcreLook struct {
+		behind bool
+		negate bool
+		node   pcreNode
+	}
+	pcreBackref struct {
+		index int
+		fold  bool
+	}
+	pcreConcat []pcreNode
+	pcreAlt    []pcreNode
+	pcreRepeat struct {
+		node       pcreNode
+		min, max   int // max == -1 - без ограничения
+		lazy       bool
+		possessive bool
+	}
+)
+
+// pcreRegexp - скомпилированный шаблон. Удовлетворяет интерфейсу matcher
+type pcreRegexp struct {
+	expr    string
+	root    pcreNode
+	groups  int
+	steps   int
+	timeout time.Duration
+}
+
+func compilePCRE(expr string, steps int, timeout time.Duration) (*pcreRegexp, error) {
+	p := &pcreParser{src: []rune(expr), names: make(map[string]int)}
+	root, err := p.parseAlt()
+	if err == nil && p.pos < len(p.src) {
+		err = p.errorf("лишняя ')'")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("неверный шаблон %q: %w", expr, err)
+	}
+	for _, ref := range p.backrefs {
+		if ref > p.groups {
+			return nil, fmt.Errorf("неверный шаблон %q: ссылка на несуществующую группу \\%d", expr, ref)
+		}
+	}
+	return &pcreRegexp{expr: expr, root: root, groups: p.groups, steps: steps, timeout: timeout}, nil
+}
+
+func isLimitError(err error) bool {
+	// isLimitError сообщает, что err - превышение лимита шагов или времени
+	return err == errPCRESteps || err == errPCRETimeout
+}
+
+func (re *pcreRegexp) String() string {
+	return re.expr
+}
+
+// MatchString сообщает, есть ли в s совпадение с шаблоном
+func (re *pcreRegexp) MatchString(s string) bool {
+	return len(re.FindAllStringIndex(s, 1)) > 0
+}
+
+// FindAllStringIndex возвращает байтовые пары [начало, конец) последовательных непересекающихся совпадений,
+// как одноименный метод regexp.Regexp. n < 0 означает "все совпадения". При превышении лимита
+// совпадений нет; узнать о превышении можно через findAll или pcreSearch
+func (re *pcreRegexp) FindAllStringIndex(s string, n int) [][]int {
+	result, _ := re.findAll(s, n)
+	return result
+}
+
+// findAll - FindAllStringIndex, который возвращает превышение лимита в этом вызове
+func (re *pcreRegexp) findAll(s string, n int) (result [][]int, err error) {
+	m := &pcreMachine{re: re, started: time.Now()}
+	m.input = []rune(s)
+	m.offsets = make([]int, 0, len(m.input)+1)
+	for i := range s {
+		m.offsets = append(m.offsets, i)
+	}
+	m.offsets = append(m.offsets, len(s))
+
+	// Превышение лимита прерывает поиск паникой с ошибкой, которую перехватываем здесь
+	defer func() {
+		if r := recover(); r != nil {
+			limitErr, ok := r.(error)
+			if !ok || !isLimitError(limitErr) {
+				panic(r)
+			}
+			result, err = nil, limitErr
+		}
+	}()
+
+	for start := 0; start <= len(m.input) && (n < 0 || len(result) < n); {
+		m.caps = make([]int, 2*(re.groups+1))
+		for i := range m.caps {
+			m.caps[i] = -1
+		}
+		end := -1
+		if m.match(re.root, start, func(e int) bool { end = e; return true }) {
+			result = append(result, []int{m.offsets[start], m.offsets[end]})
+			if end > start {
+				start = end
+				continue
+			}
+		}
+		start++
+	}
+	return result, nil
+}
+
+// pcreSearch - шаблон -P для поиска в одном входе. Запоминает первое превышение лимита в этом входе:
+// pcreRegexp общий для горутин, ищущих в разных файлах, и превышение в одном файле не должно влиять на другие
+type pcreSearch struct {
+	re  *pcreRegexp
+	err error
+}
+
+func (s *pcreSearch) MatchString(line string) bool {
+	return len(s.FindAllStringIndex(line, 1)) > 0
+}
+
+func (s *pcreSearch) FindAllStringIndex(line string, n int) [][]int {
+	result, err := s.re.findAll(line, n)
+	if s.err == nil {
+		s.err = err
+	}
+	return result
+}
+
+// pcreMachine - состояние одного поиска. Для каждой строки создается заново,
+// поэтому один pcreRegexp можно использовать из нескольких горутин
+type pcreMachine struct {
+	re      *pcreRegexp
+	input   []rune
+	offsets []int
+	caps    []int
+	steps   int
+	started time.Time
+}
+
+func (m *pcreMachine) step() {
+	m.steps++
+	if m.re.steps > 0 && m.steps > m.re.steps {
+		panic(errPCRESteps)
+	}
+	// Время проверяем не на каждом шаге: time.Since заметно дороже счетчика
+	if m.re.timeout > 0 && m.steps%1024 == 0 && time.Since(m.started) > m.re.timeout {
+		panic(errPCRETimeout)
+	}
+}
+
+func foldEqual(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b) || unicode.ToUpper(a) == unicode.ToUpper(b)
+}
+
+func (m *pcreMachine) match(node pcreNode, pos int, k func(int) bool) bool {
+	// match сопоставляет node с входом, начиная с позиции pos (в рунах), и при успехе вызывает
+	// продолжение k с позицией конца. Если продолжение вернуло false, перебираются другие варианты
+	m.step()
+	switch n := node.(type) {
+	case pcreLiteral:
+		if pos < len(m.input) && (m.input[pos] == n.r || n.fold && foldEqual(m.input[pos], n.r)) {
+			return k(pos + 1)
+		}
+		return false
+	case pcreAny:
+		if pos < len(m.input) && m.input[pos] != '\n' {
+			return k(pos + 1)
+		}
+		return false
+	case pcreClass:
+		if pos >= len(m.input) {
+			return false
+		}
+		r := m.input[pos]
+		matched := n.match(r)
+		if !matched && n.fold {
+			matched = n.match(unicode.ToLower(r)) || n.match(unicode.ToUpper(r))
+		}
+		if matched != n.negate {
+			return k(pos + 1)
+		}
+		return false
+	case pcreAssert:
+		if m.assert(n.kind, pos) {
+			return k(pos)
+		}
+		return false
+	case pcreConcat:
+		return m.matchSeq(n, pos, k)
+	case pcreAlt:
+		for _, alt := range n {
+			if m.match(alt, pos, k) {
+				return true
+			}
+		}
+		return false
+	case pcreGroup:
+		return m.matchGroup(n, pos, k)
+	case pcreLook:
+		return m.matchLook(n, pos, k)
+	case pcreBackref:
+		start, end := m.caps[2*n.index], m.caps[2*n.index+1]
+		// Ссылка на еще не совпавшую группу, как в PCRE, не совпадает ни с чем
+		if start < 0 {
+			return false
+		}
+		for i := start; i < end; i++ {
+			p := pos + i - start
+			if p >= len(m.input) || !(m.input[p] == m.input[i] || n.fold && foldEqual(m.input[p], m.input[i])) {
+				return false
+			}
+		}
+		return k(pos + end - start)
+	case pcreRepeat:
+		if n.possessive {
+			// Сверхжадный квантификатор - это атомарная группа вокруг жадного
+			greedy := n
+			greedy.possessive = false
+			return m.matchGroup(pcreGroup{atomic: true, node: greedy}, pos, k)
+		}
+		return m.matchRepeat(n, 0, pos, k)
+	}
+	panic(fmt.Sprintf("неизвестный узел шаблона %T", node))
+}
+
+func (m *pcreMachine) matchSeq(items pcreConcat, pos int, k func(int) bool) bool {
+	if len(items) == 0 {
+		return k(pos)
+	}
+	return m.match(items[0], pos, func(p int) bool {
+		return m.matchSeq(items[1:], p, k)
+	})
+}
+
+func (m *pcreMachine) matchGroup(n pcreGroup, pos int, k func(int) bool) bool {
+	if n.atomic {
+		// Атомарная группа запоминает первый найденный вариант и не возвращается внутрь нее
+		end := -1
+		saved := append([]int(nil), m.caps...)
+		if !m.match(n.node, pos, func(e int) bool { end = e; return true }) {
+			return false
+		}
+		if k(end) {
+			return true
+		}
+		copy(m.caps, saved)
+		return false
+	}
+	if n.index == 0 {
+		return m.match(n.node, pos, k)
+	}
+	// Захватывающая группа: при возврате восстанавливаем предыдущие границы
+	oldStart, oldEnd := m.caps[2*n.index], m.caps[2*n.index+1]
+	if m.match(n.node, pos, func(e int) bool {
+		prevStart, prevEnd := m.caps[2*n.index], m.caps[2*n.index+1]
+		m.caps[2*n.index], m.caps[2*n.index+1] = pos, e
+		if k(e) {
+			return true
+		}
+		m.caps[2*n.index], m.caps[2*n.index+1] = prevStart, prevEnd
+		return false
+	}) {
+		return true
+	}
+	m.caps[2*n.index], m.caps[2*n.index+1] = oldStart, oldEnd
+	return false
+}
+
+func (m *pcreMachine) matchLook(n pcreLook, pos int, k func(int) bool) bool {
+	saved := append([]int(nil), m.caps...)
+	found := false
+	if n.behind {
+		// Просмотр назад: ищем начало, с которого подшаблон заканчивается ровно в pos
+		for start := pos; start >= 0 && !found; start-- {
+			found = m.match(n.node, start, func(e int) bool { return e == pos })
+		}
+	} else {
+		found = m.match(n.node, pos, func(int) bool { return true })
+	}
+	if found == n.negate {
+		copy(m.caps, saved)
+		return false
+	}
+	// Группы внутри негативного просмотра не сохраняются
+	if n.negate {
+		copy(m.caps, saved)
+	}
+	if k(pos) {
+		return true
+	}
+	copy(m.caps, saved)
+	return false
+}
+
+func (m *pcreMachine) matchRepeat(n pcreRepeat, count, pos int, k func(int) bool) bool {
+	if n.max >= 0 && count == n.max {
+		return k(pos)
+	}
+	// Итерация, не сдвинувшая позицию, после набора минимума запрещена, иначе (a*)* зациклится
+	next := func(p int) bool {
+		if p == pos && count >= n.min {
+			return false
+		}
+		return m.matchRepeat(n, count+1, p, k)
+	}
+	if n.lazy {
+		if count >= n.min && k(pos) {
+			return true
+		}
+		return m.match(n.node, pos, next)
+	}
+	if m.match(n.node, pos, next) {
+		return true
+	}
+	return count >= n.min && k(pos)
+}
+
+func (m *pcreMachine) assert(kind rune, pos int) bool {
+	switch kind {
+	case '^', 'A':
+		return pos == 0
+	case '$', 'z':
+		return pos == len(m.input)
+	case 'b', 'B':
+		before := pos > 0 && isWordRune(m.input[pos-1])
+		after := pos < len(m.input) && isWordRune(m.input[pos])
+		return (before != after) == (kind == 'b')
+	}
+	return false
+}
+
+// pcreParser - рекурсивный спуск по шаблону
+type pcreParser struct {
+	src      []rune
+	pos      int
+	fold     bool
+	groups   int
+	names    map[string]int
+	backrefs []int
+}
+
+func (p *pcreParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(format+" в позиции %d", append(args, p.pos)...)
+}
+
+func (p *pcreParser) more() bool {
+	return p.pos < len(p.src)
+}
+
+func (p *pcreParser) peek(s string) bool {
+	return strings.HasPrefix(string(p.src[p.pos:]), s)
+}
+
+func (p *pcreParser) parseAlt() (pcreNode, error) {
+	// Флаги (?i) действуют до конца текущей группы
+	savedFold := p.fold
+	defer func() { p.fold = savedFold }()
+
+	var alts pcreAlt
+	for {
+		seq, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, seq)
+		if !p.more() || p.src[p.pos] != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return alts, nil
+}
+
+func (p *pcreParser) parseConcat() (pcreNode, error) {
+	var seq pcreConcat
+	for p.more() && p.src[p.pos] != '|' && p.src[p.pos] != ')' {
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+		if atom == nil {
+			continue
+		}
+		atom, err = p.parseQuantifier(atom)
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, atom)
+	}
+	return seq, nil
+}
+
+func (p *pcreParser) parseQuantifier(atom pcreNode) (pcreNode, error) {
+	for p.more() {
+		rep := pcreRepeat{node: atom}
+		switch c := p.src[p.pos]; c {
+		case '*':
+			rep.min, rep.max = 0, -1
+		case '+':
+			rep.min, rep.max = 1, -1
+		case '?':
+			rep.min, rep.max = 0, 1
+		case '{':
+			min, max, ok := p.parseBraces()
+			if !ok {
+				// Как в PCRE, "{" без корректного счетчика - обычный символ
+				return atom, nil
+			}
+			rep.min, rep.max = min, max
+			p.pos--
+		default:
+			return atom, nil
+		}
+		if _, ok := atom.(pcreAssert); ok {
+			return nil, p.errorf("квантификатор после утверждения нулевой ширины")
+		}
+		p.pos++
+		if p.more() && p.src[p.pos] == '?' {
+			rep.lazy = true
+			p.pos++
+		} else if p.more() && p.src[p.pos] == '+' {
+			rep.possessive = true
+			p.pos++
+		}
+		atom = rep
+	}
+	return atom, nil
+}
+
+func (p *pcreParser) parseBraces() (int, int, bool) {
+	// parseBraces разбирает {n}, {n,} или {n,m} и оставляет позицию после "}"
+	end := p.pos + 1
+	for end < len(p.src) && p.src[end] != '}' {
+		end++
+	}
+	if end == len(p.src) {
+		return 0, 0, false
+	}
+	body := string(p.src[p.pos+1 : end])
+	lo, hi, hasComma := strings.Cut(body, ",")
+	min, err := strconv.Atoi(lo)
+	if err != nil {
+		return 0, 0, false
+	}
+	max := min
+	if hasComma {
+		max = -1
+		if hi != "" {
+			if max, err = strconv.Atoi(hi); err != nil || max < min {
+				return 0, 0, false
+			}
+		}
+	}
+	p.pos = end + 1
+	return min, max, true
+}
+
+func (p *pcreParser) parseAtom() (pcreNode, error) {
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case '.':
+		return pcreAny{}, nil
+	case '^', '$':
+		return pcreAssert{kind: c}, nil
+	case '[':
+		return p.parseClass()
+	case '(':
+		return p.parseGroup()
+	case '\\':
+		return p.parseEscape()
+	case '*', '+', '?':
+		return nil, p.errorf("квантификатор %q без выражения", c)
+	}
+	return pcreLiteral{r: c, fold: p.fold}, nil
+}
+
+func (p *pcreParser) parseGroup() (pcreNode, error) {
+	group := pcreGroup{}
+	var look *pcreLook
+	switch {
+	case p.peek("?:"):
+		p.pos += 2
+	case p.peek("?>"):
+		p.pos += 2
+		group.atomic = true
+	case p.peek("?="), p.peek("?!"):
+		look = &pcreLook{negate: p.src[p.pos+1] == '!'}
+		p.pos += 2
+	case p.peek("?<="), p.peek("?<!"):
+		look = &pcreLook{behind: true, negate: p.src[p.pos+2] == '!'}
+		p.pos += 3
+	case p.peek("?<"), p.peek("?P<"):
+		p.pos += strings.Index(string(p.src[p.pos:]), "<") + 1
+		end := strings.IndexRune(string(p.src[p.pos:]), '>')
+		if end <= 0 {
+			return nil, p.errorf("неверное имя группы")
+		}
+		name := string(p.src[p.pos : p.pos+end])
+		p.pos += end + 1
+		p.groups++
+		group.index = p.groups
+		p.names[name] = group.index
+	case p.peek("?"):
+		return p.parseFlags()
+	default:
+		p.groups++
+		group.index = p.groups
+	}
+
+	node, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	if !p.more() || p.src[p.pos] != ')' {
+		return nil, p.errorf("не хватает ')'")
+	}
+	p.pos++
+	if look != nil {
+		look.node = node
+		return *look, nil
+	}
+	group.node = node
+	return group, nil
+}
+
+func (p *pcreParser) parseFlags() (pcreNode, error) {
+	// Поддерживается только флаг i: (?i), (?-i) и (?i:...)
+	p.pos++
+	fold := true
+	for p.more() {
+		switch c := p.src[p.pos]; c {
+		case '-':
+			fold = false
+		case 'i':
+		case ')':
+			p.pos++
+			p.fold = fold
+			return nil, nil
+		case ':':
+			p.pos++
+			saved := p.fold
+			p.fold = fold
+			node, err := p.parseAlt()
+			p.fold = saved
+			if err != nil {
+				return nil, err
+			}
+			if !p.more() || p.src[p.pos] != ')' {
+				return nil, p.errorf("не хватает ')'")
+			}
+			p.pos++
+			return pcreGroup{node: node}, nil
+		default:
+			return nil, p.errorf("неподдерживаемый флаг %q", c)
+		}
+		p.pos++
+	}
+	return nil, p.errorf("не хватает ')'")
+}
+
+func (p *pcreParser) parseEscape() (pcreNode, error) {
+	if !p.more() {
+		return nil, p.errorf("'\\' в конце шаблона")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch {
+	case c >= '1' && c <= '9':
+		index := int(c - '0')
+		p.backrefs = append(p.backrefs, index)
+		return pcreBackref{index: index, fold: p.fold}, nil
+	case c == 'k':
+		if !p.more() || p.src[p.pos] != '<' {
+			return nil, p.errorf("ожидалось \\k<имя>")
+		}
+		end := strings.IndexRune(string(p.src[p.pos:]), '>')
+		if end < 0 {
+			return nil, p.errorf("ожидалось \\k<имя>")
+		}
+		name := string(p.src[p.pos+1 : p.pos+end])
+		p.pos += end + 1
+		index, ok := p.names[name]
+		if !ok {
+			return nil, p.errorf("ссылка на неизвестную группу %q", name)
+		}
+		return pcreBackref{index: index, fold: p.fold}, nil
+	case c == 'b' || c == 'B' || c == 'A' || c == 'z':
+		return pcreAssert{kind: c}, nil
+	}
+	match, negate, err := p.classEscape(c)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		return pcreClass{match: match, negate: negate, fold: p.fold}, nil
+	}
+	r, err := p.literalEscape(c)
+	if err != nil {
+		return nil, err
+	}
+	return pcreLiteral{r: r, fold: p.fold}, nil
+}
+
+func (p *pcreParser) classEscape(c rune) (func(rune) bool, bool, error) {
+	// classEscape разбирает \d \w \s \p{...} и их отрицания. Возвращает nil, если c - не класс.
+	// \w и \d понимают Unicode, чтобы работать с кириллицей, как grep -P в UTF-8 локали
+	switch c {
+	case 'd', 'D':
+		return unicode.IsDigit, c == 'D', nil
+	case 'w', 'W':
+		return isWordRune, c == 'W', nil
+	case 's', 'S':
+		return unicode.IsSpace, c == 'S', nil
+	case 'p', 'P':
+		name := ""
+		if p.more() && p.src[p.pos] == '{' {
+			end := strings.IndexRune(string(p.src[p.pos:]), '}')
+			if end < 0 {
+				return nil, false, p.errorf("не хватает '}'")
+			}
+			name = string(p.src[p.pos+1 : p.pos+end])
+			p.pos += end + 1
+		} else if p.more() {
+			name = string(p.src[p.pos])
+			p.pos++
+		}
+		table, ok := unicode.Categories[name]
+		if !ok {
+			table, ok = unicode.Scripts[name]
+		}
+		if !ok {
+			return nil, false, p.errorf("неизвестный класс Unicode %q", name)
+		}
+		return func(r rune) bool { return unicode.Is(table, r) }, c == 'P', nil
+	}
+	return nil, false, nil
+}
+
+func (p *pcreParser) literalEscape(c rune) (rune, error) {
+	switch c {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case 'f':
+		return '\f', nil
+	case 'v':
+		return '\v', nil
+	case 'e':
+		return 0x1b, nil
+	case '0':
+		return 0, nil
+	case 'x':
+		// \xHH или \x{HHHH}
+		digits := ""
+		if p.more() && p.src[p.pos] == '{' {
+			end := strings.IndexRune(string(p.src[p.pos:]), '}')
+			if end < 0 {
+				return 0, p.errorf("не хватает '}'")
+			}
+			digits = string(p.src[p.pos+1 : p.pos+end])
+			p.pos += end + 1
+		} else if p.pos+2 <= len(p.src) {
+			digits = string(p.src[p.pos : p.pos+2])
+			p.pos += 2
+		}
+		code, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil {
+			return 0, p.errorf("неверный код символа \\x%s", digits)
+		}
+		return rune(code), nil
+	}
+	// Экранированные буквы и цифры без особого смысла - ошибка, как в PCRE; остальное - сам символ
+	if unicode.IsLetter(c) || unicode.IsDigit(c) {
+		return 0, p.errorf("неизвестное экранирование \\%c", c)
+	}
+	return c, nil
+}
+
+func (p *pcreParser) parseClass() (pcreNode, error) {
+	// parseClass разбирает [...] и [^...]. "]" сразу после "[" или "[^" - обычный символ
+	class := pcreClass{fold: p.fold}
+	if p.more() && p.src[p.pos] == '^' {
+		class.negate = true
+		p.pos++
+	}
+	var parts []func(rune) bool
+	first := true
+	for {
+		if !p.more() {
+			return nil, p.errorf("не хватает ']'")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		if c == ']' && !first {
+			break
+		}
+		first = false
+
+		lo := c
+		if c == '\\' {
+			if !p.more() {
+				return nil, p.errorf("'\\' в конце шаблона")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			match, negate, err := p.classEscape(e)
+			if err != nil {
+				return nil, err
+			}
+			if match != nil {
+				if negate {
+					inner := match
+					match = func(r rune) bool { return !inner(r) }
+				}
+				parts = append(parts, match)
+				continue
+			}
+			if e == 'b' {
+				lo = '\b'
+			} else if lo, err = p.literalEscape(e); err != nil {
+				return nil, err
+			}
+		}
+
+		// Диапазон a-z. "-" в конце класса - обычный символ
+		hi := lo
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '-' && p.src[p.pos+1] != ']' {
+			p.pos++
+			hi = p.src[p.pos]
+			p.pos++
+			if hi == '\\' {
+				if !p.more() {
+					return nil, p.errorf("'\\' в конце шаблона")
+				}
+				var err error
+				if hi, err = p.literalEscape(p.src[p.pos]); err != nil {
+					return nil, err
+				}
+				p.pos++
+			}
+			if hi < lo {
+				return nil, p.errorf("неверный диапазон %c-%c", lo, hi)
+			}
+		}
+		from, to := lo, hi
+		parts = append(parts, func(r rune) bool { return r >= from && r <= to })
+	}
+	class.match = func(r rune) bool {
+		for _, part := range parts {
+			if part(r) {
+				return true
+			}
+		}
+		return false
+	}
+	return class, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPCREFeatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		line     string
+		expected [][]int
+	}{
+		{"обратная ссылка", `(\w+) \1`, "это это повтор", [][]int{{0, 13}}},
+		{"обратная ссылка без совпадения", `(\w+) \1`, "один два", nil},
+		{"именованная группа", `(?<q>['"]).*?\k<q>`, `a 'b' "c'`, [][]int{{2, 5}}},
+		{"просмотр вперед", `\d+(?= руб)`, "10 usd, 20 руб", [][]int{{8, 10}}},
+		{"негативный просмотр вперед", `foo(?!bar)`, "foobar foobaz", [][]int{{7, 10}}},
+		{"просмотр назад", `(?<=user=)\w+`, "id=1 user=ivan", [][]int{{10, 14}}},
+		{"негативный просмотр назад", `(?<!-)\b\d+`, "-5 7", [][]int{{3, 4}}},
+		{"ленивый квантификатор", `<.+?>`, "<a><b>", [][]int{{0, 3}, {3, 6}}},
+		{"сверхжадный квантификатор", `a++a`, "aaaa", nil},
+		{"атомарная группа", `(?>a|ab)c`, "abc", nil},
+		{"флаг i в группе", `(?i:ошибка) X`, "ОШИБКА X ОШИБКА x", [][]int{{0, 14}}},
+		{"обратная ссылка без учета регистра", `(?i)(a)\1`, "aA", [][]int{{0, 2}}},
+		{"счетчик", `a{2,3}`, "aaaaa", [][]int{{0, 3}, {3, 5}}},
+		{"фигурная скобка как символ", `a{x}`, "a{x}", [][]int{{0, 4}}},
+		{"класс и диапазон", `[^а-я\s]+`, "слово WORD", [][]int{{11, 15}}},
+		{"юникодные классы", `\p{Cyrillic}+`, "abc где", [][]int{{4, 10}}},
+		{"граница слова в кириллице", `\bлог\b`, "логи лог", [][]int{{9, 15}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			re, err := compilePCRE(test.expr, defaultPCRESteps, defaultPCRETimeout)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			result := re.FindAllStringIndex(test.line, -1)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestPCREMatchesRE2(t *testing.T) {
+	// На шаблонах, которые понимают оба движка, результаты должны совпадать с regexp
+	exprs := []string{`a+b`, `(ab|a)c`, `^\d{2,}`, `x*`, `[a-c]+$`, `(?i)Hello`, `\s+\S`, `colou?r`}
+	lines := []string{"", "aab ac abc", "123 45", "xxyx", "abcabc", "HELLO hello", "a  b\tc", "color colour"}
+	for _, expr := range exprs {
+		re, err := compilePCRE(expr, defaultPCRESteps, defaultPCRETimeout)
+		if err != nil {
+			t.Fatalf("%s: неожиданная ошибка: %v", expr, err)
+		}
+		reg := regexp.MustCompile(expr)
+		for _, line := range lines {
+			expected := reg.FindAllStringIndex(line, -1)
+			result := re.FindAllStringIndex(line, -1)
+			// Пустые совпадения движки перебирают по-разному, для grep они не важны
+			if !reflect.DeepEqual(matchNonEmpty(result), matchNonEmpty(expected)) {
+				t.Errorf("%s на %q: ожидалось: %v, получено: %v", expr, line, expected, result)
+			}
+		}
+	}
+}
+
+func matchNonEmpty(locs [][]int) [][]int {
+	var result [][]int
+	for _, loc := range locs {
+		if loc[0] != loc[1] {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
+
+func TestPCREErrors(t *testing.T) {
+	for _, expr := range []string{`(a`, `a)`, `*a`, `[a`, `\2(a)`, `\k<x>`, `[z-a]`, `(?x)a`, `\q`} {
+		if _, err := compilePCRE(expr, defaultPCRESteps, defaultPCRETimeout); err == nil {
+			t.Errorf("%s: ожидалась ошибка", expr)
+		}
+	}
+}
+
+func TestPCRELimits(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// Катастрофический шаблон: без лимита поиск занял бы экспоненциальное время
+	line := strings.Repeat("a", 40) + "!"
+	re, err := compilePCRE(`(a+)+$`, 10_000, 0)
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	if result, err := re.findAll(line, 1); result != nil || err != errPCRESteps {
+		t.Errorf("Ожидалось превышение лимита шагов")
+	}
+
+	re, err = compilePCRE(`(a+)+$`, 0, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	start := time.Now()
+	if result, err := re.findAll(line, 1); result != nil || err != errPCRETimeout {
+		t.Errorf("Ожидалось превышение лимита времени")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Поиск не был прерван вовремя: %v", elapsed)
+	}
+
+	// Превышение лимита дает код возврата 2
+	fileName, err := createTestFile(line + "\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	cfg := &grepConfig{perl: true, pcreSteps: 10_000}
+	if code := run(cfg, []string{`(a+)+$`, fileName}, io.Discard); code != exitError {
+		t.Errorf("Ожидался код: %v, получено: %v", exitError, code)
+	}
+
+	// После превышения лимита поиск останавливается, а сообщение печатается один раз
+	many, err := createTestFile(strings.Repeat(line+"\n", 50) + "aaa\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(many)
+	var messages bytes.Buffer
+	log.SetOutput(&messages)
+	var out bytes.Buffer
+	cfg = &grepConfig{perl: true, pcreSteps: 10_000, workers: 4}
+	if code := run(cfg, []string{`(a+)+$`, many, fileName}, &out); code != exitError {
+		t.Errorf("Ожидался код: %v, получено: %v", exitError, code)
+	}
+	if strings.Count(messages.String(), "\n") != 1 {
+		t.Errorf("Ожидалось одно сообщение, получено: %q", messages.String())
+	}
+	if out.Len() != 0 {
+		t.Errorf("Ожидалось, что поиск остановится до строки aaa, получено: %q", out.String())
+	}
+
+	// Превышение лимита в одном файле не мешает поиску в файлах перед ним: с -j 1 и -j 4 вывод одинаковый
+	good, err := createTestFile(strings.Repeat("line\n", 100_000))
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(good)
+	after, err := createTestFile("line\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(after)
+	expected := good + ":100000\n"
+	for _, workers := range []int{1, 4} {
+		messages.Reset()
+		out.Reset()
+		cfg = &grepConfig{perl: true, pcreSteps: 10_000, count: true, workers: workers}
+		if code := run(cfg, []string{`(a+)+$|line`, good, many, after}, &out); code != exitError {
+			t.Errorf("-j %d: ожидался код: %v, получено: %v", workers, exitError, code)
+		}
+		if out.String() != expected {
+			t.Errorf("-j %d: ожидалось: %q, получено: %q", workers, expected, out.String())
+		}
+		if strings.Count(messages.String(), "\n") != 1 {
+			t.Errorf("-j %d: ожидалось одно сообщение, получено: %q", workers, messages.String())
+		}
+	}
+}
+
+func TestCreatePCRE(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		patterns []string
+		line     string
+		expected [][]int
+	}{
+		{"-w", grepConfig{perl: true, wordRegexp: true}, []string{"лог"}, "логи лог", [][]int{{9, 15}}},
+		{"-x", grepConfig{perl: true, lineRegexp: true}, []string{`(\w)\1`}, "aa", [][]int{{0, 2}}},
+		{"-i и несколько шаблонов", grepConfig{perl: true, ignoreCase: true}, []string{"a", "б"}, "AБ", [][]int{{0, 1}, {1, 3}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := createMatcher(&test.cfg, test.patterns)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			result := m.FindAllStringIndex(test.line, -1)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+		})
+	}
+
+	if _, err := createMatcher(&grepConfig{perl: true, fixed: true}, []string{"a"}); err == nil {
+		t.Errorf("Ожидалась ошибка для -P вместе с -F")
+	}
+}
@@ -2,11 +2,23 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 /*
@@ -24,6 +36,33 @@ import (
 -F - "fixed", точное совпадение со строкой, не паттерн
 -n - "line num", печатать номер строки
 
+Дополнительно:
+Принимает несколько файлов, "-" или отсутствие файлов означает STDIN
+-r - рекурсивный обход директорий (--include, --exclude, --exclude-dir - glob-фильтры по имени)
+-H / -h - печатать / не печатать имя файла перед строкой
+-l / -L - выводить только имена файлов с совпадениями / без совпадений
+-m NUM - остановиться после NUM выбранных строк
+--line-buffered - сбрасывать вывод после каждой строки (для tail -f | grep)
+-o - печатать только совпавшие части строк
+-b - печатать смещение в байтах от начала файла
+--color=auto|always|never - подсвечивать совпадения ANSI-кодами
+-e PATTERN - шаблон (можно указать несколько раз), -f FILE - шаблоны из файла, по одному на строку
+-w / -x - совпадение должно быть целым словом / целой строкой
+-q - ничего не печатать, только код возврата; -s - не сообщать об ошибках чтения файлов
+-P - шаблоны в стиле PCRE: обратные ссылки и просмотр вперед/назад (см. pcre.go),
+     --pcre-steps и --pcre-timeout ограничивают поиск в одной строке
+-j N - искать в N файлах параллельно (вывод все равно идет в порядке аргументов, см. parallel.go)
+-a - искать в двоичных файлах как в тексте; -I - пропускать двоичные файлы.
+     Без них для двоичного файла печатается только "Binary file ... matches"
+--no-ignore - не учитывать .gitignore при рекурсивном поиске (см. gitignore.go)
+-z - искать внутри файлов, сжатых gzip, bzip2 и zstd (см. compress.go)
+--json - выводить события поиска в формате JSON Lines, как ripgrep (см. json.go)
+
+Код возврата как у grep: 0 - есть совпадения, 1 - совпадений нет, 2 - ошибка.
+
+Ввод обрабатывается потоково: в памяти хранится только текущая строка и контекст -B,
+поэтому grep работает с файлами любого размера и с бесконечным вводом.
+
 Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 */
 
@@ -36,8 +75,68 @@ type grepConfig struct {
 	invert     bool
 	fixed      bool
 	lineNum    bool
+
+	recursive         bool
+	withFilename      bool
+	noFilename        bool
+	filesWithMatches  bool
+	filesWithoutMatch bool
+	include           stringList
+	exclude           stringList
+	excludeDir        stringList
+
+	maxCount     int
+	lineBuffered bool
+
+	onlyMatching bool
+	byteOffset   bool
+	color        string
+	// colorize вычисляется из color и того, является ли STDOUT терминалом
+	colorize bool
+
+	patterns     stringList
+	patternFiles stringList
+	wordRegexp   bool
+	lineRegexp   bool
+
+	quiet      bool
+	noMessages bool
+
+	perl        bool
+	pcreSteps   int
+	pcreTimeout time.Duration
+
+	workers            int
+	text               bool
+	binaryWithoutMatch bool
+	noIgnore           bool
+
+	searchZip bool
+	json      bool
+}
+
+// Коды возврата grep
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+// stringList - значение флага, которое можно указать несколько раз (--include=*.go --include=*.md)
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
 
+// Имя, под которым выводится стандартный ввод
+const stdinName = "(standard input)"
+
 func (cfg *grepConfig) parseConfig() {
 	flag.IntVar(&cfg.after, "A", 0, "печатать +N строк после совпадения")
 	flag.IntVar(&cfg.before, "B", 0, "печатать +N строк до совпадения")
@@ -47,128 +146,860 @@ func (cfg *grepConfig) parseConfig() {
 	flag.BoolVar(&cfg.invert, "v", false, "вместо совпадения, исключать")
 	flag.BoolVar(&cfg.fixed, "F", false, "точное совпадение со строкой, не паттерн")
 	flag.BoolVar(&cfg.lineNum, "n", false, "печатать номер строки")
+	flag.BoolVar(&cfg.recursive, "r", false, "рекурсивно обходить директории")
+	flag.BoolVar(&cfg.withFilename, "H", false, "печатать имя файла для каждого совпадения")
+	flag.BoolVar(&cfg.noFilename, "h", false, "не печатать имя файла")
+	flag.BoolVar(&cfg.filesWithMatches, "l", false, "выводить только имена файлов с совпадениями")
+	flag.BoolVar(&cfg.filesWithoutMatch, "L", false, "выводить только имена файлов без совпадений")
+	flag.Var(&cfg.include, "include", "искать только в файлах, имя которых подходит под glob")
+	flag.Var(&cfg.exclude, "exclude", "пропускать файлы, имя которых подходит под glob")
+	flag.Var(&cfg.excludeDir, "exclude-dir", "пропускать директории, имя которых подходит под glob")
+	flag.IntVar(&cfg.maxCount, "m", 0, "остановиться после NUM выбранных строк (0 - без ограничений)")
+	flag.BoolVar(&cfg.lineBuffered, "line-buffered", false, "сбрасывать вывод после каждой строки")
+	flag.BoolVar(&cfg.onlyMatching, "o", false, "печатать только совпавшие части строк, каждую на новой строке")
+	flag.BoolVar(&cfg.byteOffset, "b", false, "печатать смещение в байтах перед строкой")
+	flag.StringVar(&cfg.color, "color", "never", "подсвечивать совпадения: auto, always или never")
+	flag.Var(&cfg.patterns, "e", "шаблон для поиска (можно указать несколько раз)")
+	flag.Var(&cfg.patternFiles, "f", "читать шаблоны из файла, по одному на строку")
+	flag.BoolVar(&cfg.wordRegexp, "w", false, "совпадение должно быть целым словом")
+	flag.BoolVar(&cfg.lineRegexp, "x", false, "совпадение должно быть целой строкой")
+	flag.BoolVar(&cfg.quiet, "q", false, "ничего не печатать, выйти с кодом 0 при первом совпадении")
+	flag.BoolVar(&cfg.noMessages, "s", false, "не сообщать о несуществующих и нечитаемых файлах")
+	flag.BoolVar(&cfg.perl, "P", false, "шаблоны в стиле PCRE (обратные ссылки, просмотр вперед и назад)")
+	flag.IntVar(&cfg.pcreSteps, "pcre-steps", defaultPCRESteps, "максимум шагов поиска в одной строке для -P (0 - без ограничений)")
+	flag.DurationVar(&cfg.pcreTimeout, "pcre-timeout", defaultPCRETimeout, "максимальное время поиска в одной строке для -P (0 - без ограничений)")
+	flag.IntVar(&cfg.workers, "j", runtime.NumCPU(), "количество файлов, в которых поиск идет параллельно")
+	flag.BoolVar(&cfg.text, "a", false, "искать в двоичных файлах как в тексте")
+	flag.BoolVar(&cfg.binaryWithoutMatch, "I", false, "пропускать двоичные файлы")
+	flag.BoolVar(&cfg.noIgnore, "no-ignore", false, "не учитывать .gitignore при рекурсивном поиске")
+	flag.BoolVar(&cfg.searchZip, "z", false, "искать внутри сжатых файлов (gzip, bzip2, zstd)")
+	flag.BoolVar(&cfg.json, "json", false, "выводить результаты в формате JSON Lines")
 	flag.Parse()
+
+	// Запоминаем, какие флаги были указаны явно, чтобы -A и -B имели приоритет над -C
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	cfg.resolveContext(explicit)
+}
+
+func (cfg *grepConfig) resolveContext(explicit map[string]bool) {
+	// Как в grep: -C задает значение по умолчанию и для -A, и для -B,
+	// а явно указанные -A и -B переопределяют его независимо друг от друга
+	if !explicit["A"] {
+		cfg.after = max(cfg.after, cfg.context)
+	}
+	if !explicit["B"] {
+		cfg.before = max(cfg.before, cfg.context)
+	}
+}
+
+func openInput(name string) (io.ReadCloser, error) {
+	// "-" означает стандартный ввод, все остальное - путь до файла.
+	// STDIN не закрываем: им владеет процесс, а не grep
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+func matchAnyGlob(globs []string, name string) bool {
+	// matchAnyGlob сообщает, подходит ли имя файла хотя бы под один glob
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func includeFile(cfg *grepConfig, path string) bool {
+	// Фильтры --include и --exclude применяются к имени файла, а не к полному пути, как в grep
+	name := filepath.Base(path)
+	if len(cfg.include) > 0 && !matchAnyGlob(cfg.include, name) {
+		return false
+	}
+	return !matchAnyGlob(cfg.exclude, name)
+}
+
+func collectInputs(cfg *grepConfig, args []string) ([]string, []error) {
+	// collectInputs раскрывает аргументы командной строки в список файлов для поиска.
+	// Ошибки не прерывают обход: grep сообщает о них и продолжает работу с остальными файлами
+	var (
+		inputs []string
+		errs   []error
+	)
+	// Без файлов grep читает STDIN, а с флагом -r - текущую директорию
+	if len(args) == 0 {
+		if cfg.recursive {
+			args = []string{"."}
+		} else {
+			args = []string{"-"}
+		}
+	}
+	for _, arg := range args {
+		if arg == "-" {
+			inputs = append(inputs, arg)
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !info.IsDir() {
+			// Файлы, явно указанные в аргументах, не фильтруются через --include/--exclude
+			inputs = append(inputs, arg)
+			continue
+		}
+		if !cfg.recursive {
+			errs = append(errs, fmt.Errorf("%s: является директорией", arg))
+			continue
+		}
+		// rulesByDir - правила .gitignore, действующие внутри директории: ее собственные и всех родителей до arg
+		rulesByDir := make(map[string][]ignoreRule)
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			parentRules := rulesByDir[filepath.Dir(path)]
+			if path != arg && !cfg.noIgnore && (d.Name() == ".git" || isIgnored(parentRules, path, d.IsDir())) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path != arg && matchAnyGlob(cfg.excludeDir, d.Name()) {
+					return filepath.SkipDir
+				}
+				if !cfg.noIgnore {
+					rules, err := loadGitignore(path)
+					if err != nil {
+						errs = append(errs, err)
+					}
+					rulesByDir[path] = append(parentRules[:len(parentRules):len(parentRules)], rules...)
+				}
+				return nil
+			}
+			if d.Type().IsRegular() && includeFile(cfg, path) {
+				inputs = append(inputs, path)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return inputs, errs
+}
+
+func showFilename(cfg *grepConfig, inputs []string) bool {
+	// Как в grep: имя файла печатается, если файлов несколько или включен рекурсивный поиск.
+	// -h и -H явно переопределяют это поведение
+	switch {
+	case cfg.noFilename:
+		return false
+	case cfg.withFilename:
+		return true
+	default:
+		return cfg.recursive || len(inputs) > 1
+	}
+}
+
+func displayName(name string) string {
+	if name == "-" {
+		return stdinName
+	}
+	return name
+}
+
+// matcher - то, чем ищутся совпадения в строке. Ему удовлетворяет *regexp.Regexp,
+// а также ahoCorasick и обертки для -w и -x
+type matcher interface {
+	MatchString(s string) bool
+	FindAllStringIndex(s string, n int) [][]int
 }
 
-func readLinesFromFile(fileName string) ([]string, error) {
-	var result []string
-	file, err := os.Open(fileName)
+// Начиная с этого количества фиксированных строк, вместо альтернативы "a|b|c|..." используется Ахо-Корасик
+const ahoCorasickThreshold = 16
+
+func readPatterns(cfg *grepConfig, args []string) ([]string, []string, error) {
+	// readPatterns собирает шаблоны из -e и -f. Если ни один из этих флагов не указан,
+	// шаблоном считается первый позиционный аргумент. Возвращает шаблоны и оставшиеся аргументы
+	patterns := append([]string(nil), cfg.patterns...)
+	for _, fileName := range cfg.patternFiles {
+		input, err := openInput(fileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		scanner := bufio.NewScanner(input)
+		for scanner.Scan() {
+			patterns = append(patterns, trimEOL(scanner.Text()))
+		}
+		input.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(cfg.patterns) > 0 || len(cfg.patternFiles) > 0 {
+		return patterns, args, nil
+	}
+	if len(args) < 1 {
+		return nil, nil, errors.New("пропущен паттерн")
+	}
+	// Паттерн идет первым элементов в args
+	return []string{args[0]}, args[1:], nil
+}
+
+func createRegexp(cfg *grepConfig, patterns ...string) (*regexp.Regexp, error) {
+	// createRegexp объединяет шаблоны в одно регулярное выражение через альтернативу "|"
+	if len(patterns) == 0 {
+		// Пустой список шаблонов (например, пустой файл в -f) не совпадает ни с чем, как в grep
+		return regexp.Compile(`[^\x00-\x{10FFFF}]`)
+	}
+	parts := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		if cfg.fixed {
+			// Если флаг fixed, используем точное совпадение строки
+			// QuoteMeta возвращает строку, которая экранирует все метасимволы регулярных выражений внутри
+			// текста аргумента; возвращаемая строка представляет собой регулярное выражение, соответствующее буквальному тексту
+			pattern = regexp.QuoteMeta(pattern)
+		}
+		parts[i] = pattern
+	}
+	expr := strings.Join(parts, "|")
+	// С флагом -x совпадение должно занимать всю строку. Границы слов для -w проверяет wordMatcher,
+	// потому что \b в RE2 понимает только ASCII и не работает с кириллицей
+	if cfg.lineRegexp {
+		expr = "^(?:" + expr + ")$"
+	}
+	// Если флаг ignore-case, добавляем (?i) для игнорирования регистра. Флаг действует и вместе с -F
+	if cfg.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	// Компиляция регулярного выражения для сопоставления с текстом.
+	// Compile вместо MustCompile: неверный шаблон - ошибка пользователя, а не повод для паники
+	reg, err := regexp.Compile(expr)
 	if err != nil {
-		return result, err
+		// Ошибка regexp содержит итоговое выражение, поэтому показываем только ее код и исходные шаблоны
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("неверный шаблон %q: %s", strings.Join(patterns, "|"), syntaxErr.Code)
+		}
+		return nil, fmt.Errorf("неверный шаблон: %w", err)
 	}
-	defer file.Close()
+	return reg, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		result = append(result, scanner.Text())
+func createMatcher(cfg *grepConfig, patterns []string) (matcher, error) {
+	// createMatcher выбирает способ поиска: Ахо-Корасик для большого списка фиксированных строк,
+	// иначе регулярное выражение. Затем при необходимости добавляет проверку -w или -x
+	if cfg.perl {
+		return createPCRE(cfg, patterns)
 	}
-	return result, scanner.Err()
+	var m matcher
+	if cfg.fixed && len(patterns) >= ahoCorasickThreshold && !containsEmpty(patterns) {
+		m = newAhoCorasick(patterns, cfg.ignoreCase)
+		if cfg.lineRegexp {
+			m = lineMatcher{m}
+		}
+	} else {
+		reg, err := createRegexp(cfg, patterns...)
+		if err != nil {
+			return nil, err
+		}
+		m = reg
+	}
+	if cfg.wordRegexp && !cfg.lineRegexp {
+		m = wordMatcher{m}
+	}
+	return m, nil
 }
 
-func createRegexp(cfg *grepConfig, pattern string) *regexp.Regexp {
-	var reg *regexp.Regexp
+func createPCRE(cfg *grepConfig, patterns []string) (matcher, error) {
+	// createPCRE собирает шаблоны для движка с возвратами. В отличие от RE2, здесь есть просмотр назад,
+	// поэтому -w выражается прямо в шаблоне, без обертки wordMatcher
 	if cfg.fixed {
-		// Если флаг fixed, используем точное совпадение строки
-		// QuoteMeta возвращает строку, которая экранирует все метасимволы регулярных выражений внутри
-		// текста аргумента; возвращаемая строка представляет собой регулярное выражение, соответствующее буквальному тексту
-		reg = regexp.MustCompile(regexp.QuoteMeta(pattern))
-	} else {
-		if cfg.ignoreCase {
-			// Если флаг ignore-case, добавляем (?i) для игнорирования регистра
-			reg = regexp.MustCompile("(?i)" + pattern)
-		} else {
-			// Компиляция регулярного выражения без игнорирования регистра для сопоставления с текстом
-			reg = regexp.MustCompile(pattern)
+		return nil, errors.New("флаги -P и -F несовместимы")
+	}
+	if len(patterns) == 0 {
+		return compilePCRE(`(?!)`, cfg.pcreSteps, cfg.pcreTimeout)
+	}
+	expr := strings.Join(patterns, "|")
+	switch {
+	case cfg.lineRegexp:
+		expr = "^(?:" + expr + ")$"
+	case cfg.wordRegexp:
+		expr = `(?<!\w)(?:` + expr + `)(?!\w)`
+	}
+	if cfg.ignoreCase {
+		expr = "(?i)" + expr
+	}
+	return compilePCRE(expr, cfg.pcreSteps, cfg.pcreTimeout)
+}
+
+func containsEmpty(patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return true
 		}
 	}
-	return reg
+	return false
 }
 
-func searchMatchLines(resultReadLines []string, cfg *grepConfig, reg *regexp.Regexp) []int {
-	// Возвращает массив для хранения индексов строк, соответствующих шаблону
-	matchedLines := make([]int, 0)
-	for idx, line := range resultReadLines {
-		// MatchString сообщает, содержит ли строка какое-либо совпадение с регулярным выражением reg
-		matched := reg.MatchString(line)
-		// Если инвертирование включено и строка не соответствует шаблону,
-		// или если инвертирование выключено и строка соответствует шаблону
-		if (cfg.invert && !matched) || (!cfg.invert && matched) {
-			matchedLines = append(matchedLines, idx)
+func isWordRune(r rune) bool {
+	// Составляющие слова в grep: буквы, цифры и подчеркивание
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordBoundary(s string, start, end int) bool {
+	// wordBoundary сообщает, что s[start:end] не окружен составляющими слова
+	if r, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isWordRune(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && isWordRune(r) {
+		return false
+	}
+	return true
+}
+
+// wordMatcher оставляет только совпадения, которые являются целыми словами (-w)
+type wordMatcher struct {
+	m matcher
+}
+
+func (wm wordMatcher) MatchString(s string) bool {
+	return len(wm.FindAllStringIndex(s, 1)) > 0
+}
+
+func (wm wordMatcher) FindAllStringIndex(s string, n int) [][]int {
+	// Как в grep: если совпадение не является словом, поиск продолжается со следующей руны,
+	// поэтому для "foo_bar foo" и шаблона "foo" найдется второе вхождение
+	var result [][]int
+	for pos := 0; pos <= len(s) && (n < 0 || len(result) < n); {
+		locs := wm.m.FindAllStringIndex(s[pos:], 1)
+		if len(locs) == 0 {
+			break
 		}
+		start, end := pos+locs[0][0], pos+locs[0][1]
+		if start != end && wordBoundary(s, start, end) {
+			result = append(result, []int{start, end})
+			pos = end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[start:])
+		pos = start + max(size, 1)
+	}
+	return result
+}
+
+// lineMatcher оставляет только совпадение, занимающее всю строку (-x)
+type lineMatcher struct {
+	m matcher
+}
+
+func (lm lineMatcher) MatchString(s string) bool {
+	return len(lm.FindAllStringIndex(s, 1)) > 0
+}
+
+func (lm lineMatcher) FindAllStringIndex(s string, _ int) [][]int {
+	// Самое левое и самое длинное совпадение занимает всю строку, только если строка целиком совпала с шаблоном
+	locs := lm.m.FindAllStringIndex(s, 1)
+	if len(locs) == 0 || locs[0][0] != 0 || locs[0][1] != len(s) {
+		return nil
+	}
+	return locs
+}
+
+func lineSelected(cfg *grepConfig, reg matcher, line string) bool {
+	// MatchString сообщает, содержит ли строка какое-либо совпадение с регулярным выражением reg
+	matched := reg.MatchString(line)
+	// Строка выбрана, если инвертирование включено и строка не соответствует шаблону,
+	// или если инвертирование выключено и строка соответствует шаблону
+	return matched != cfg.invert
+}
+
+// contextLine - строка, запомненная для вывода в качестве контекста
+type contextLine struct {
+	num    int
+	offset int64
+	text   string
+}
+
+// ringBuffer хранит последние N строк для флага -B. Память ограничена N строками
+// независимо от размера входных данных
+type ringBuffer struct {
+	lines []contextLine
+	start int
+	size  int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	return &ringBuffer{lines: make([]contextLine, capacity)}
+}
+
+func (rb *ringBuffer) push(line contextLine) {
+	// При заполненном буфере самая старая строка перезаписывается
+	if len(rb.lines) == 0 {
+		return
+	}
+	end := (rb.start + rb.size) % len(rb.lines)
+	rb.lines[end] = line
+	if rb.size < len(rb.lines) {
+		rb.size++
+	} else {
+		rb.start = (rb.start + 1) % len(rb.lines)
+	}
+}
+
+func (rb *ringBuffer) drain() []contextLine {
+	// drain возвращает запомненные строки в порядке поступления и очищает буфер
+	result := make([]contextLine, 0, rb.size)
+	for i := 0; i < rb.size; i++ {
+		result = append(result, rb.lines[(rb.start+i)%len(rb.lines)])
 	}
-	return matchedLines
+	rb.start, rb.size = 0, 0
+	return result
 }
 
-func countLines(matchedLines []int) int {
-	return len(matchedLines)
+func trimEOL(line string) string {
+	// Убираем перевод строки, в том числе виндовый "\r\n", как это делает bufio.ScanLines
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
 }
 
-func findIdxLines(cfg *grepConfig, matchedLines []int, resultReadLines []string) map[int]bool {
-	// Собираем уникальные индексы строк для вывода, учитывая флаги after, before и context
-	linesToPrint := make(map[int]bool)
-	for _, idx := range matchedLines {
-		// Определение начало и конец вывода. если флаги у before и after не установлены, то они = 0
-		start := max(0, idx-cfg.before)
-		end := min(len(resultReadLines)-1, idx+cfg.after)
+func searchMatchLines(r io.Reader, w *bufio.Writer, cfg *grepConfig, reg matcher, name string) (int, error) {
+	// searchMatchLines читает r построчно и сразу печатает выбранные строки с контекстом в w.
+	// В памяти хранятся только текущая строка и до cfg.before строк контекста, поэтому
+	// grep работает с файлами любого размера и с бесконечным вводом (tail -f | grep).
+	// Если w == nil, строки не печатаются, а только считаются (флаги -c, -l, -L).
+	// Возвращает количество выбранных строк
+	reader := bufio.NewReader(r)
+	reg, limitError := limitChecked(reg)
+	before := newRingBuffer(cfg.before)
+	withContext := cfg.before > 0 || cfg.after > 0
+	// afterLeft - сколько строк контекста после совпадения осталось напечатать
+	afterLeft := 0
+	// lastPrinted - номер последней напечатанной строки, нужен для разделителя "--"
+	lastPrinted := 0
+	selected := 0
+	// offset - смещение начала текущей строки в байтах от начала ввода
+	var offset int64
+
+	// В режиме --json вместо строк печатаются события, а в конце файла - событие end
+	var js *jsonFile
+	if cfg.json && w != nil {
+		js = newJSONFile(name)
+		defer func() { js.end(w, offset, nil) }()
+	}
+
+	emit := func(line contextLine, marker string) {
+		if js != nil {
+			js.line(w, cfg, reg, marker, line)
+			if cfg.lineBuffered {
+				w.Flush()
+			}
+			return
+		}
+		if withContext && lastPrinted > 0 && line.num != lastPrinted+1 {
+			w.WriteString("--\n")
+		}
+		lastPrinted = line.num
+		// С флагом -o строки контекста не печатаются, но разделители групп сохраняются, как в grep
+		if cfg.onlyMatching && marker == "-" {
+			return
+		}
+		printLine(w, cfg, reg, name, marker, line)
+		// С флагом --line-buffered каждая строка сразу уходит в вывод, иначе буфер сбрасывается по заполнению
+		if cfg.lineBuffered {
+			w.Flush()
+		}
+	}
+
+	for num := 1; ; num++ {
+		raw, err := reader.ReadString('\n')
+		if len(raw) == 0 {
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return selected, err
+			}
+		}
+		line := contextLine{num: num, offset: offset, text: trimEOL(raw)}
+		offset += int64(len(raw))
 
-		// Если установлен флаг context, переопределяем start и end
-		if cfg.context > 0 {
-			start = max(0, idx-cfg.context)
-			end = min(len(resultReadLines)-1, idx+cfg.context)
+		switch {
+		case cfg.maxCount > 0 && selected >= cfg.maxCount:
+			// После -m NUM совпадений допечатываем только хвост контекста и останавливаемся
+			if afterLeft == 0 || w == nil {
+				return selected, nil
+			}
+			emit(line, "-")
+			afterLeft--
+		case lineSelected(cfg, reg, line.text):
+			selected++
+			if w == nil {
+				break
+			}
+			for _, ctx := range before.drain() {
+				emit(ctx, "-")
+			}
+			emit(line, ":")
+			afterLeft = cfg.after
+		case afterLeft > 0 && w != nil:
+			emit(line, "-")
+			afterLeft--
+		default:
+			before.push(line)
 		}
-		// Добавляем строки в диапазоне [start, end] в результат
-		for j := start; j <= end; j++ {
-			linesToPrint[j] = true
+
+		// Превышение лимитов -P останавливает поиск, как в grep -P, а не повторяется в каждой строке
+		if limitErr := limitError(); limitErr != nil {
+			return selected, limitErr
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return selected, err
 		}
 	}
-	return linesToPrint
+	return selected, nil
 }
 
-func main() {
-	var cfg grepConfig
-	cfg.parseConfig()
+func limitChecked(reg matcher) (matcher, func() error) {
+	// limitChecked готовит reg к поиску в одном входе. Вторым значением возвращает проверку,
+	// было ли в этом входе превышение лимитов движка -P. Другие движки лимитов не имеют
+	re, ok := reg.(*pcreRegexp)
+	if !ok {
+		return reg, func() error { return nil }
+	}
+	search := &pcreSearch{re: re}
+	return search, func() error { return search.err }
+}
 
-	// Args - элементы которые идут после вызова программы. Пример: go run task.go Args
-	args := flag.Args()
-	// Проверка что ввели паттерн и название файла
-	if len(args) < 2 {
-		log.Println("Пропущен паттерн и/или название файла")
-		os.Exit(1)
+// ANSI-коды для подсветки совпадений, как у GNU grep по умолчанию
+const (
+	colorMatch = "\x1b[01;31m\x1b[K"
+	colorReset = "\x1b[m\x1b[K"
+)
+
+func colorEnabled(mode string, isTerminal bool) (bool, error) {
+	// colorEnabled разбирает значение --color. В режиме auto подсветка включается,
+	// только если вывод идет в терминал, чтобы ANSI-коды не попадали в файлы и пайпы
+	switch mode {
+	case "always":
+		return true, nil
+	case "never", "":
+		return false, nil
+	case "auto":
+		return isTerminal, nil
+	default:
+		return false, fmt.Errorf("недопустимое значение --color: %q", mode)
 	}
-	// Паттерн идет первым элементов в args
-	pattern := args[0]
-	// Название идет вторым элементво в args
-	inputFile := args[1]
-	// Считываем указанный в args файл
-	resultReadLines, err := readLinesFromFile(inputFile)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func highlight(text string, locs [][]int) string {
+	// highlight оборачивает найденные участки строки в ANSI-коды цвета
+	var sb strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		sb.WriteString(text[prev:loc[0]])
+		sb.WriteString(colorMatch + text[loc[0]:loc[1]] + colorReset)
+		prev = loc[1]
 	}
+	sb.WriteString(text[prev:])
+	return sb.String()
+}
 
-	// Компиляция регулярного выражения для сопоставления с текстом
-	reg := createRegexp(&cfg, pattern)
+func matchLocations(reg matcher, text string) [][]int {
+	// FindAllStringIndex возвращает пары [начало, конец) всех совпадений.
+	// Пустые совпадения (например, у шаблона "a*") не печатаются и не подсвечиваются
+	var result [][]int
+	for _, loc := range reg.FindAllStringIndex(text, -1) {
+		if loc[0] != loc[1] {
+			result = append(result, loc)
+		}
+	}
+	return result
+}
+
+func printPrefix(w *bufio.Writer, cfg *grepConfig, name, marker string, num int, offset int64) {
+	// Порядок как в grep: имя файла, номер строки, смещение в байтах.
+	// Совпадения помечаются ':', строки контекста - '-'
+	if name != "" {
+		w.WriteString(name + marker)
+	}
+	// Если флаг lineNum установлен, выводим номер строки
+	if cfg.lineNum {
+		fmt.Fprintf(w, "%d%s", num, marker)
+	}
+	if cfg.byteOffset {
+		fmt.Fprintf(w, "%d%s", offset, marker)
+	}
+}
 
-	// Создание массива для хранения индексов строк, соответствующих шаблону []int
-	matchedLines := searchMatchLines(resultReadLines, &cfg, reg)
+func printLine(w *bufio.Writer, cfg *grepConfig, reg matcher, name, marker string, line contextLine) {
+	// Совпавшие участки ищем только когда они нужны: для -o и для подсветки.
+	// В инвертированном режиме выбранные строки не содержат совпадений
+	var locs [][]int
+	if (cfg.onlyMatching || cfg.colorize) && !cfg.invert {
+		locs = matchLocations(reg, line.text)
+	}
 
-	// Если флаг count установлен, выводим количество строк и завершаем выполнение
-	if cfg.count {
-		result := countLines(matchedLines)
-		log.Println("количество совпадающих строк:", result)
+	// С флагом -o каждое совпадение печатается на отдельной строке, а -b дает смещение самого совпадения
+	if cfg.onlyMatching {
+		for _, loc := range locs {
+			printPrefix(w, cfg, name, marker, line.num, line.offset+int64(loc[0]))
+			part := line.text[loc[0]:loc[1]]
+			if cfg.colorize {
+				part = colorMatch + part + colorReset
+			}
+			w.WriteString(part + "\n")
+		}
 		return
 	}
 
-	// Собираем уникальные индексы строк для вывода, учитывая флаги after, before и context
-	linesToPrint := findIdxLines(&cfg, matchedLines, resultReadLines)
+	printPrefix(w, cfg, name, marker, line.num, line.offset)
+	// Выводим строку
+	text := line.text
+	if cfg.colorize && marker == ":" {
+		text = highlight(text, locs)
+	}
+	w.WriteString(text + "\n")
+}
+
+// Сколько байт из первого чтения проверяется на нулевой байт, чтобы признать файл двоичным
+const binaryPeekSize = 8 * 1024
+
+func grepInput(w *bufio.Writer, cfg *grepConfig, reg matcher, name string, withName bool) (int, error) {
+	// grepInput выполняет поиск в одном файле (или STDIN) и печатает результат в w.
+	// Возвращает количество выбранных строк
+	input, err := openInput(name)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+
+	// Имя файла перед каждой строкой вывода. В событиях --json путь указывается всегда
+	prefix := ""
+	if withName || cfg.json {
+		prefix = displayName(name)
+	}
 
-	// Печать строк
-	for i := range linesToPrint {
-		// Если флаг lineNum установлен, выводим номер строки
-		if cfg.lineNum {
-			fmt.Printf("%d:", i+1)
+	// С флагами -q, -c, -l и -L сами строки не печатаются
+	listOnly := cfg.quiet || cfg.count || cfg.filesWithMatches || cfg.filesWithoutMatch
+	lineWriter := w
+	if listOnly {
+		lineWriter = nil
+	}
+	// С флагами -q, -l и -L достаточно первого совпадения
+	lcfg := *cfg
+	if cfg.quiet || cfg.filesWithMatches || cfg.filesWithoutMatch {
+		lcfg.maxCount = 1
+	}
+
+	// Сжатый файл распаковываем на лету. Имя файла в выводе остается исходным
+	var source io.Reader = input
+	if cfg.searchZip {
+		unpacked, err := decompress(bufio.NewReader(input))
+		if err != nil {
+			return 0, err
+		}
+		defer unpacked.Close()
+		source = unpacked
+	}
+
+	// Двоичный файл, как в grep, определяем по нулевому байту в начале. Смотрим только на то, что пришло
+	// первым чтением: ждать binaryPeekSize байт из пайпа значило бы задержать вывод (tail -f | grep).
+	// Peek не сдвигает позицию чтения
+	reader := bufio.NewReaderSize(source, 64*1024)
+	reader.Peek(1)
+	head, _ := reader.Peek(min(reader.Buffered(), binaryPeekSize))
+	binaryOffset := bytes.IndexByte(head, 0)
+	binary := !cfg.text && binaryOffset >= 0
+	if binary {
+		if cfg.binaryWithoutMatch {
+			return 0, nil
+		}
+		// Строки двоичного файла не печатаются: достаточно узнать, есть ли совпадение
+		if !listOnly {
+			lineWriter = nil
+			lcfg.maxCount = 1
+		}
+	}
+
+	selected, err := searchMatchLines(reader, lineWriter, &lcfg, reg, prefix)
+	if err != nil || cfg.quiet {
+		return selected, err
+	}
+
+	switch {
+	// С флагами -l и -L печатается только имя файла
+	case cfg.filesWithMatches || cfg.filesWithoutMatch:
+		if (cfg.filesWithMatches && selected > 0) || (cfg.filesWithoutMatch && selected == 0) {
+			fmt.Fprintln(w, displayName(name))
+		}
+	// Если флаг count установлен, выводим количество строк: по одному числу на файл, как в grep
+	case cfg.count:
+		if prefix != "" {
+			prefix += ":"
+		}
+		fmt.Fprintf(w, "%s%d\n", prefix, selected)
+	case binary && selected > 0 && cfg.json:
+		writeJSONBinary(w, displayName(name), int64(binaryOffset), selected)
+	case binary && selected > 0:
+		fmt.Fprintf(w, "Binary file %s matches\n", displayName(name))
+	}
+	return selected, nil
+}
+
+func errorMessage(err error) string {
+	// errorMessage убирает из ошибок файловой системы название операции ("open", "stat"),
+	// оставляя путь и причину, как в сообщениях grep
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Path + ": " + pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func run(cfg *grepConfig, args []string, stdout io.Writer) int {
+	// run выполняет поиск и возвращает код возврата grep
+	started := time.Now()
+	if cfg.json && (cfg.count || cfg.filesWithMatches || cfg.filesWithoutMatch) {
+		log.Println("--json несовместим с -c, -l и -L")
+		return exitError
+	}
+	// Шаблоны берутся из -e и -f, а без них - из первого аргумента
+	patterns, args, err := readPatterns(cfg, args)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	// Компиляция шаблонов для сопоставления с текстом
+	reg, err := createMatcher(cfg, patterns)
+	if err != nil {
+		log.Println(err)
+		return exitError
+	}
+
+	// Остальные аргументы - файлы и директории. Без них читается STDIN
+	inputs, errs := collectInputs(cfg, args)
+	hadError := len(errs) > 0
+	if !cfg.noMessages {
+		for _, err := range errs {
+			log.Println(errorMessage(err))
 		}
-		// Выводим строку
-		fmt.Println(resultReadLines[i])
 	}
+	// Как в grep, наличие имени файла зависит от числа аргументов, а не от того, сколько файлов удалось открыть
+	withName := showFilename(cfg, args)
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	matched := false
+	var limitErr error
+	summary := jsonSummaryStats{Searches: len(inputs)}
+	// handle учитывает результат поиска в одном файле. Возвращает true, если дальше искать не нужно
+	handle := func(result fileResult) bool {
+		summary.MatchedLines += result.selected
+		if result.selected > 0 {
+			summary.SearchesWithMatch++
+			matched = true
+			// С флагом -q первое совпадение сразу завершает работу с кодом 0, даже если были ошибки
+			if cfg.quiet {
+				return true
+			}
+		}
+		// Превышение лимитов -P останавливает весь поиск, сообщение о нем печатается ниже один раз
+		if isLimitError(result.err) {
+			limitErr = result.err
+			return true
+		}
+		if result.err != nil {
+			// Ошибка чтения одного файла не прерывает поиск в остальных, но меняет код возврата на 2
+			hadError = true
+			if cfg.noMessages {
+				return false
+			}
+			// Ошибки открытия файла уже содержат путь, а ошибкам чтения добавляем имя файла
+			err := result.err
+			if !errors.As(err, new(*fs.PathError)) {
+				err = fmt.Errorf("%s: %w", displayName(result.name), err)
+			}
+			// Сообщение об ошибке должно идти после уже найденных строк
+			out.Flush()
+			log.Println(errorMessage(err))
+		}
+		return false
+	}
+
+	// Несколько файлов ищутся параллельно. С --line-buffered важна задержка, а не пропускная способность,
+	// поэтому вывод идет сразу, без буферизации целого файла. STDIN может быть бесконечным (tail -f),
+	// и его вывод нельзя копить, пока ищутся файлы перед ним, поэтому с "-" поиск последовательный
+	if cfg.workers > 1 && len(inputs) > 1 && !cfg.lineBuffered && !slices.Contains(inputs, "-") {
+		searchParallel(cfg, reg, inputs, withName, out, handle)
+	} else {
+		for _, name := range inputs {
+			selected, err := grepInput(out, cfg, reg, name, withName)
+			if handle(fileResult{name: name, selected: selected, err: err}) {
+				break
+			}
+		}
+	}
+	if cfg.quiet && matched {
+		return exitMatch
+	}
+
+	if cfg.json && !cfg.quiet {
+		writeJSONSummary(out, summary, time.Since(started))
+	}
+
+	// Превышение лимитов движка -P - ошибка, как в grep -P. Сообщение идет после уже найденных строк
+	if limitErr != nil {
+		hadError = true
+		out.Flush()
+		log.Println(limitErr)
+	}
+
+	switch {
+	case hadError:
+		return exitError
+	case matched:
+		return exitMatch
+	default:
+		return exitNoMatch
+	}
+}
+
+func main() {
+	// Сообщения об ошибках в стиле grep: без даты и времени, с именем утилиты
+	log.SetFlags(0)
+	log.SetPrefix("grep: ")
+
+	var cfg grepConfig
+	cfg.parseConfig()
+
+	colorize, err := colorEnabled(cfg.color, isTerminal(os.Stdout))
+	if err != nil {
+		log.Println(err)
+		os.Exit(exitError)
+	}
+	cfg.colorize = colorize
+
+	// Args - элементы которые идут после вызова программы. Пример: go run task.go Args
+	os.Exit(run(&cfg, flag.Args(), os.Stdout))
 }
@@ -1,18 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestReadLinesFromFile(t *testing.T) {
-	content := `Это тестовый файл.
-Он содержит несколько строк.
-Некоторые из них совпадают с шаблоном.
-Шаблон - это слово, которое мы ищем.
-Это конец файла.`
+// Вспомогательная функция: прогоняет searchMatchLines по content и возвращает вывод
+func runSearch(content string, cfg *grepConfig, reg *regexp.Regexp, name string) (string, int, error) {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	selected, err := searchMatchLines(strings.NewReader(content), w, cfg, reg, name)
+	w.Flush()
+	return out.String(), selected, err
+}
+
+func TestReadFromFile(t *testing.T) {
+	// Последняя строка без перевода строки и "\r\n" должны читаться так же, как bufio.ScanLines
+	content := "Это тестовый файл.\r\nОн содержит несколько строк.\nЭто конец файла."
 
 	fileName, err := createTestFile(content)
 	if err != nil {
@@ -20,28 +33,26 @@ func TestReadLinesFromFile(t *testing.T) {
 	}
 	defer os.Remove(fileName)
 
-	lines, err := readLinesFromFile(fileName)
-	if err != nil {
+	var out bytes.Buffer
+	w := bufio.NewWriter(&out)
+	if _, err := grepInput(w, &grepConfig{lineNum: true}, regexp.MustCompile(""), fileName, false); err != nil {
 		t.Fatalf("Не удалось прочитать строки из файла: %v", err)
 	}
+	w.Flush()
 
-	expectedLines := []string{
-		"Это тестовый файл.",
-		"Он содержит несколько строк.",
-		"Некоторые из них совпадают с шаблоном.",
-		"Шаблон - это слово, которое мы ищем.",
-		"Это конец файла.",
-	}
-
-	if !reflect.DeepEqual(lines, expectedLines) {
-		t.Errorf("Ожидалось: %v, получено: %v", expectedLines, lines)
+	expected := "1:Это тестовый файл.\n2:Он содержит несколько строк.\n3:Это конец файла.\n"
+	if out.String() != expected {
+		t.Errorf("Ожидалось: %q, получено: %q", expected, out.String())
 	}
 }
 
 func TestCreateRegexp(t *testing.T) {
 	cfg := &grepConfig{ignoreCase: true}
 	pattern := "шаблон"
-	reg := createRegexp(cfg, pattern)
+	reg, err := createRegexp(cfg, pattern)
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
 	expectedPattern := "(?i)шаблон"
 
 	if reg.String() != expectedPattern {
@@ -50,60 +61,608 @@ func TestCreateRegexp(t *testing.T) {
 
 	cfg.fixed = true
 	cfg.ignoreCase = false
-	reg = createRegexp(cfg, pattern)
+	reg, err = createRegexp(cfg, pattern)
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
 	expectedPattern = regexp.QuoteMeta(pattern)
 
 	if reg.String() != expectedPattern {
 		t.Errorf("Ожидалось: %v, получено: %v", expectedPattern, reg.String())
 	}
+
+	// Неверный шаблон возвращает понятную ошибку вместо паники
+	_, err = createRegexp(&grepConfig{}, "a(b")
+	expectedErr := `неверный шаблон "a(b": missing closing )`
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Ожидалось: %v, получено: %v", expectedErr, err)
+	}
 }
 
-func TestSearchMatchLines(t *testing.T) {
-	lines := []string{
-		"Это тестовый файл.",
-		"Он содержит несколько строк.",
-		"Некоторые из них совпадают с шаблоном.",
-		"Шаблон - это слово, которое мы ищем.",
-		"Это конец файла.",
+func TestCreateMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		patterns []string
+		line     string
+		expected [][]int
+	}{
+		{"-F и -i вместе", grepConfig{fixed: true, ignoreCase: true}, []string{"a.b"}, "A.B axb", [][]int{{0, 3}}},
+		{"несколько шаблонов", grepConfig{}, []string{"foo", "ba+r"}, "foo baar", [][]int{{0, 3}, {4, 8}}},
+		{"целая строка", grepConfig{lineRegexp: true}, []string{"foo", "bar"}, "foo bar", nil},
+		{"целая строка совпала", grepConfig{lineRegexp: true}, []string{"foo", "foo bar"}, "foo bar", [][]int{{0, 7}}},
+		{"целое слово", grepConfig{wordRegexp: true}, []string{"foo"}, "foo_bar foobar foo", [][]int{{15, 18}}},
+		{"целое слово на кириллице", grepConfig{wordRegexp: true, ignoreCase: true}, []string{"лог"}, "логи, Лог.", [][]int{{10, 16}}},
+		{"пустой список шаблонов", grepConfig{}, nil, "что угодно", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := createMatcher(&test.cfg, test.patterns)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			result := m.FindAllStringIndex(test.line, -1)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+			if m.MatchString(test.line) != (test.expected != nil) {
+				t.Errorf("MatchString: ожидалось: %v", test.expected != nil)
+			}
+		})
+	}
+}
+
+func TestCreateMatcherAhoCorasick(t *testing.T) {
+	// Большой список фиксированных строк ищется автоматом Ахо-Корасик
+	var patterns []string
+	for i := 0; i < ahoCorasickThreshold*4; i++ {
+		patterns = append(patterns, fmt.Sprintf("user_%d", i))
 	}
+	if m, _ := createMatcher(&grepConfig{fixed: true}, patterns); !isAhoCorasick(m) {
+		t.Errorf("Ожидался автомат Ахо-Корасик")
+	}
+
+	line := "login USER_7 user_42, user_420 user_5x"
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		expected [][]int
+	}{
+		// Среди совпадений в одной позиции выбирается самое длинное: user_42, а не user_4
+		{"-F", grepConfig{fixed: true}, [][]int{{13, 20}, {22, 29}, {31, 37}}},
+		{"-F -i", grepConfig{fixed: true, ignoreCase: true}, [][]int{{6, 12}, {13, 20}, {22, 29}, {31, 37}}},
+		{"-F -w", grepConfig{fixed: true, wordRegexp: true}, [][]int{{13, 20}}},
+		{"-F -x", grepConfig{fixed: true, lineRegexp: true}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := createMatcher(&test.cfg, patterns)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			result := m.FindAllStringIndex(line, -1)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+		})
+	}
+
+	if m, _ := createMatcher(&grepConfig{fixed: true, lineRegexp: true}, patterns); !m.MatchString("user_63") {
+		t.Errorf("Ожидалось совпадение целой строки")
+	}
+}
+
+func isAhoCorasick(m matcher) bool {
+	_, ok := m.(*ahoCorasick)
+	return ok
+}
+
+func TestReadPatterns(t *testing.T) {
+	fileName, err := createTestFile("первый\nвторой\r\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(fileName)
+
+	tests := []struct {
+		name             string
+		cfg              grepConfig
+		args             []string
+		expectedPatterns []string
+		expectedArgs     []string
+	}{
+		{"позиционный шаблон", grepConfig{}, []string{"шаблон", "file"}, []string{"шаблон"}, []string{"file"}},
+		{"флаги -e", grepConfig{patterns: stringList{"a", "b"}}, []string{"file"}, []string{"a", "b"}, []string{"file"}},
+		{"флаги -e и -f", grepConfig{patterns: stringList{"a"}, patternFiles: stringList{fileName}}, nil, []string{"a", "первый", "второй"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patterns, args, err := readPatterns(&test.cfg, test.args)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if !reflect.DeepEqual(patterns, test.expectedPatterns) || !reflect.DeepEqual(args, test.expectedArgs) {
+				t.Errorf("Ожидалось: %v %v, получено: %v %v", test.expectedPatterns, test.expectedArgs, patterns, args)
+			}
+		})
+	}
+
+	if _, _, err := readPatterns(&grepConfig{}, nil); err == nil {
+		t.Errorf("Ожидалась ошибка при отсутствии шаблона")
+	}
+}
+
+func TestSearchMatchLines(t *testing.T) {
+	content := `Это тестовый файл.
+Он содержит несколько строк.
+Некоторые из них совпадают с шаблоном.
+Шаблон - это слово, которое мы ищем.
+Это конец файла.`
 
 	cfg := &grepConfig{}
 	reg := regexp.MustCompile("Шаблон")
 
-	matchedLines := searchMatchLines(lines, cfg, reg)
-	expectedMatchedLines := []int{3}
+	out, selected, err := runSearch(content, cfg, reg, "")
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	expected := "Шаблон - это слово, которое мы ищем.\n"
+	if out != expected || selected != 1 {
+		t.Errorf("Ожидалось: %q (1), получено: %q (%v)", expected, out, selected)
+	}
+
+	// Без вывода строки только считаются
+	cfg = &grepConfig{invert: true}
+	selected, err = searchMatchLines(strings.NewReader(content), nil, cfg, reg, "")
+	if err != nil || selected != 4 {
+		t.Errorf("Ожидалось: %v, получено: %v (%v)", 4, selected, err)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	rb := newRingBuffer(2)
+	for i := 1; i <= 5; i++ {
+		rb.push(contextLine{num: i})
+	}
+	expected := []contextLine{{num: 4}, {num: 5}}
+	if result := rb.drain(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, result)
+	}
+	if result := rb.drain(); len(result) != 0 {
+		t.Errorf("Ожидался пустой буфер, получено: %v", result)
+	}
+
+	// Буфер нулевой емкости (без -B) ничего не хранит
+	rb = newRingBuffer(0)
+	rb.push(contextLine{num: 1})
+	if result := rb.drain(); len(result) != 0 {
+		t.Errorf("Ожидался пустой буфер, получено: %v", result)
+	}
+}
+
+func TestResolveContext(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            grepConfig
+		explicit       map[string]bool
+		expectedBefore int
+		expectedAfter  int
+	}{
+		{"только -C", grepConfig{context: 2}, map[string]bool{"C": true}, 2, 2},
+		{"-C и -A", grepConfig{context: 2, after: 5}, map[string]bool{"C": true, "A": true}, 2, 5},
+		{"-C и явный -B 0", grepConfig{context: 2}, map[string]bool{"C": true, "B": true}, 0, 2},
+		{"-A и -B без -C", grepConfig{after: 1, before: 3}, map[string]bool{"A": true, "B": true}, 3, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.cfg.resolveContext(test.explicit)
+			if test.cfg.before != test.expectedBefore || test.cfg.after != test.expectedAfter {
+				t.Errorf("Ожидалось: B=%v A=%v, получено: B=%v A=%v",
+					test.expectedBefore, test.expectedAfter, test.cfg.before, test.cfg.after)
+			}
+		})
+	}
+}
 
-	if !reflect.DeepEqual(matchedLines, expectedMatchedLines) {
-		t.Errorf("Ожидалось: %v, получено: %v", expectedMatchedLines, matchedLines)
+func TestSearchMatchLinesContext(t *testing.T) {
+	content := "a\nшаблон\nb\nc\nd\ne\nшаблон\nf\n"
+	reg := regexp.MustCompile("шаблон")
+
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		file     string
+		expected string
+	}{
+		{
+			name:     "без контекста",
+			cfg:      grepConfig{},
+			expected: "шаблон\nшаблон\n",
+		},
+		{
+			name:     "номера строк и разделитель групп",
+			cfg:      grepConfig{before: 1, after: 1, lineNum: true},
+			expected: "1-a\n2:шаблон\n3-b\n--\n6-e\n7:шаблон\n8-f\n",
+		},
+		{
+			name:     "смежные группы не разделяются",
+			cfg:      grepConfig{before: 4, lineNum: true},
+			expected: "1-a\n2:шаблон\n3-b\n4-c\n5-d\n6-e\n7:шаблон\n",
+		},
+		{
+			name:     "пересекающийся контекст не повторяется",
+			cfg:      grepConfig{before: 2, after: 4, lineNum: true},
+			expected: "1-a\n2:шаблон\n3-b\n4-c\n5-d\n6-e\n7:шаблон\n8-f\n",
+		},
+		{
+			name:     "имя файла",
+			cfg:      grepConfig{after: 1},
+			file:     "f.txt",
+			expected: "f.txt:шаблон\nf.txt-b\n--\nf.txt:шаблон\nf.txt-f\n",
+		},
+		{
+			name:     "-m с хвостом контекста",
+			cfg:      grepConfig{maxCount: 1, after: 2, lineNum: true},
+			expected: "2:шаблон\n3-b\n4-c\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, _, err := runSearch(content, &test.cfg, reg, test.file)
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, out)
+			}
+		})
 	}
 }
 
-func TestCountLines(t *testing.T) {
-	matchedLines := []int{1, 2, 3}
-	count := countLines(matchedLines)
-	expectedCount := 3
+func TestSearchMatchLinesStreaming(t *testing.T) {
+	// Вывод должен появляться до окончания ввода: пишем строку в пайп и ждем ее на выходе
+	pr, pw := io.Pipe()
+	lines := make(chan string)
+	w := bufio.NewWriter(writerFunc(func(p []byte) (int, error) {
+		lines <- string(p)
+		return len(p), nil
+	}))
+	done := make(chan error)
+	go func() {
+		_, err := searchMatchLines(pr, w, &grepConfig{lineBuffered: true}, regexp.MustCompile("ERROR"), "")
+		done <- err
+	}()
+
+	pw.Write([]byte("INFO старт\nERROR первая\n"))
+	if line := <-lines; line != "ERROR первая\n" {
+		t.Errorf("Ожидалось: %q, получено: %q", "ERROR первая\n", line)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+}
+
+func TestRunStreaming(t *testing.T) {
+	// Проверка на двоичный файл не должна ждать binaryPeekSize байт: строка из пайпа STDIN
+	// печатается, пока ввод еще не закончился
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = pr
+	defer func() { os.Stdin = stdin }()
+
+	lines := make(chan string, 2)
+	out := writerFunc(func(p []byte) (int, error) {
+		lines <- string(p)
+		return len(p), nil
+	})
+	done := make(chan int)
+	go func() {
+		done <- run(&grepConfig{lineBuffered: true, workers: 4}, []string{"foo"}, out)
+	}()
 
-	if count != expectedCount {
-		t.Errorf("Ожидалось: %v, получено: %v", expectedCount, count)
+	pw.Write([]byte("foo 1\nbar\n"))
+	select {
+	case line := <-lines:
+		if line != "foo 1\n" {
+			t.Errorf("Ожидалось: %q, получено: %q", "foo 1\n", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Строка не напечатана до конца ввода")
+	}
+	pw.Write([]byte("foo 2\n"))
+	pw.Close()
+	if code := <-done; code != exitMatch {
+		t.Errorf("Ожидался код: %v, получено: %v", exitMatch, code)
 	}
+	pr.Close()
+	if line := <-lines; line != "foo 2\n" {
+		t.Errorf("Ожидалось: %q, получено: %q", "foo 2\n", line)
+	}
+}
+
+// writerFunc позволяет использовать функцию как io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
 }
 
-func TestFindIdxLines(t *testing.T) {
-	lines := []string{
-		"Это тестовый файл.",
-		"Он содержит несколько строк.",
-		"Некоторые из них совпадают с шаблоном.",
-		"Шаблон - это слово, которое мы ищем.",
-		"Это конец файла.",
+func TestOnlyMatchingAndByteOffset(t *testing.T) {
+	content := "id=1 id=22\nпусто\nпривет id=333\n"
+	reg := regexp.MustCompile(`id=\d+`)
+
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		expected string
+	}{
+		{
+			name:     "флаг -o",
+			cfg:      grepConfig{onlyMatching: true},
+			expected: "id=1\nid=22\nid=333\n",
+		},
+		{
+			name:     "флаг -o с номером строки и смещением",
+			cfg:      grepConfig{onlyMatching: true, lineNum: true, byteOffset: true},
+			expected: "1:0:id=1\n1:5:id=22\n3:35:id=333\n",
+		},
+		{
+			name: "флаг -b для целых строк",
+			cfg:  grepConfig{byteOffset: true},
+			// Кириллица занимает 2 байта на символ: "пусто\n" - 11 байт, поэтому третья строка начинается с 11+11=22
+			expected: "0:id=1 id=22\n22:привет id=333\n",
+		},
+		{
+			name:     "флаг -o с -v ничего не печатает",
+			cfg:      grepConfig{onlyMatching: true, invert: true},
+			expected: "",
+		},
+		{
+			name:     "флаг -o без строк контекста",
+			cfg:      grepConfig{onlyMatching: true, after: 1},
+			expected: "id=1\nid=22\nid=333\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, _, err := runSearch(content, &test.cfg, reg, "")
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, out)
+			}
+		})
 	}
+}
 
-	cfg := &grepConfig{before: 1, after: 1}
-	matchedLines := []int{3}
-	linesToPrint := findIdxLines(cfg, matchedLines, lines)
-	expectedLinesToPrint := map[int]bool{2: true, 3: true, 4: true}
+func TestColor(t *testing.T) {
+	tests := []struct {
+		mode       string
+		isTerminal bool
+		expected   bool
+		wantErr    bool
+	}{
+		{"always", false, true, false},
+		{"never", true, false, false},
+		{"auto", true, true, false},
+		{"auto", false, false, false},
+		{"sometimes", true, false, true},
+	}
+	for _, test := range tests {
+		result, err := colorEnabled(test.mode, test.isTerminal)
+		if result != test.expected || (err != nil) != test.wantErr {
+			t.Errorf("%s: ожидалось: %v (ошибка %v), получено: %v (%v)", test.mode, test.expected, test.wantErr, result, err)
+		}
+	}
 
-	if !reflect.DeepEqual(linesToPrint, expectedLinesToPrint) {
-		t.Errorf("Ожидалось: %v, получено: %v", expectedLinesToPrint, linesToPrint)
+	cfg := &grepConfig{colorize: true, after: 1}
+	out, _, err := runSearch("ab ab\nконтекст\n", cfg, regexp.MustCompile("ab"), "")
+	if err != nil {
+		t.Fatalf("Неожиданная ошибка: %v", err)
+	}
+	// Подсвечиваются совпадения в выбранных строках, строки контекста печатаются как есть
+	expected := colorMatch + "ab" + colorReset + " " + colorMatch + "ab" + colorReset + "\nконтекст\n"
+	if out != expected {
+		t.Errorf("Ожидалось: %q, получено: %q", expected, out)
+	}
+}
+
+func TestCollectInputs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":            "package a",
+		"b.txt":           "текст",
+		"sub/c.go":        "package c",
+		"vendor/d.go":     "package d",
+		"sub/deep/e.conf": "key=value",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		args     []string
+		expected []string
+		errCount int
+	}{
+		{
+			name:     "без аргументов читается STDIN",
+			cfg:      grepConfig{},
+			args:     nil,
+			expected: []string{"-"},
+		},
+		{
+			name:     "директория без -r - ошибка",
+			cfg:      grepConfig{},
+			args:     []string{dir, filepath.Join(dir, "a.go")},
+			expected: []string{filepath.Join(dir, "a.go")},
+			errCount: 1,
+		},
+		{
+			name: "рекурсивный обход",
+			cfg:  grepConfig{recursive: true},
+			args: []string{dir},
+			expected: []string{
+				filepath.Join(dir, "a.go"),
+				filepath.Join(dir, "b.txt"),
+				filepath.Join(dir, "sub/c.go"),
+				filepath.Join(dir, "sub/deep/e.conf"),
+				filepath.Join(dir, "vendor/d.go"),
+			},
+		},
+		{
+			name: "include и exclude-dir",
+			cfg:  grepConfig{recursive: true, include: stringList{"*.go"}, excludeDir: stringList{"vendor"}},
+			args: []string{dir},
+			expected: []string{
+				filepath.Join(dir, "a.go"),
+				filepath.Join(dir, "sub/c.go"),
+			},
+		},
+		{
+			name: "exclude",
+			cfg:  grepConfig{recursive: true, exclude: stringList{"*.go"}},
+			args: []string{dir, "-"},
+			expected: []string{
+				filepath.Join(dir, "b.txt"),
+				filepath.Join(dir, "sub/deep/e.conf"),
+				"-",
+			},
+		},
+		{
+			name:     "несуществующий файл",
+			cfg:      grepConfig{},
+			args:     []string{filepath.Join(dir, "нет.txt")},
+			expected: nil,
+			errCount: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputs, errs := collectInputs(&test.cfg, test.args)
+			if !reflect.DeepEqual(inputs, test.expected) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, inputs)
+			}
+			if len(errs) != test.errCount {
+				t.Errorf("Ожидалось ошибок: %v, получено: %v", test.errCount, errs)
+			}
+		})
+	}
+}
+
+func TestShowFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		inputs   []string
+		expected bool
+	}{
+		{"один файл", grepConfig{}, []string{"a"}, false},
+		{"несколько файлов", grepConfig{}, []string{"a", "b"}, true},
+		{"рекурсивный поиск", grepConfig{recursive: true}, []string{"a"}, true},
+		{"флаг -H", grepConfig{withFilename: true}, []string{"a"}, true},
+		{"флаг -h", grepConfig{noFilename: true}, []string{"a", "b"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := showFilename(&test.cfg, test.inputs); result != test.expected {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestGrepInputFilesWithMatches(t *testing.T) {
+	matching, err := createTestFile("первая строка\nшаблон\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(matching)
+	notMatching, err := createTestFile("первая строка\nвторая строка\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(notMatching)
+
+	reg := regexp.MustCompile("шаблон")
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		expected string
+	}{
+		{"флаг -l", grepConfig{filesWithMatches: true}, matching + "\n"},
+		{"флаг -L", grepConfig{filesWithoutMatch: true}, notMatching + "\n"},
+		{"флаг -H", grepConfig{withFilename: true}, matching + ":шаблон\n"},
+		{"флаг -c", grepConfig{count: true}, "1\n0\n"},
+		{"флаг -c с именем файла", grepConfig{count: true, withFilename: true}, matching + ":1\n" + notMatching + ":0\n"},
+		{"флаг -q", grepConfig{quiet: true}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := bufio.NewWriter(&out)
+			for _, name := range []string{matching, notMatching} {
+				if _, err := grepInput(w, &test.cfg, reg, name, test.cfg.withFilename); err != nil {
+					t.Fatalf("Неожиданная ошибка: %v", err)
+				}
+			}
+			w.Flush()
+			if out.String() != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestRunExitCode(t *testing.T) {
+	matching, err := createTestFile("шаблон\n")
+	if err != nil {
+		t.Fatalf("Не удалось создать временный файл: %v", err)
+	}
+	defer os.Remove(matching)
+	missing := filepath.Join(t.TempDir(), "нет.txt")
+
+	tests := []struct {
+		name     string
+		cfg      grepConfig
+		args     []string
+		expected int
+		output   string
+	}{
+		{"есть совпадения", grepConfig{}, []string{"шаблон", matching}, exitMatch, "шаблон\n"},
+		{"нет совпадений", grepConfig{}, []string{"другое", matching}, exitNoMatch, ""},
+		{"неверный шаблон", grepConfig{}, []string{"(", matching}, exitError, ""},
+		{"нет шаблона", grepConfig{}, nil, exitError, ""},
+		{"ошибка чтения файла", grepConfig{}, []string{"шаблон", matching, missing}, exitError, matching + ":шаблон\n"},
+		{"ошибка с -s", grepConfig{noMessages: true}, []string{"шаблон", missing}, exitError, ""},
+		{"-q с совпадением и ошибкой", grepConfig{quiet: true}, []string{"шаблон", missing, matching}, exitMatch, ""},
+		{"-q без совпадений", grepConfig{quiet: true}, []string{"другое", matching}, exitNoMatch, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			code := run(&test.cfg, test.args, &out)
+			if code != test.expected {
+				t.Errorf("Ожидался код: %v, получено: %v", test.expected, code)
+			}
+			if out.String() != test.output {
+				t.Errorf("Ожидалось: %q, получено: %q", test.output, out.String())
+			}
+		})
 	}
 }
 
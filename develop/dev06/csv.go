@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+/*
+Режим --csv: ввод разбирается по RFC 4180 через encoding/csv.
+
+Поле в кавычках может содержать разделитель, кавычки ("") и переводы строк, поэтому
+запись CSV не всегда совпадает со строкой файла. Выбранные поля записываются через csv.Writer:
+поле с разделителем, кавычкой или переводом строки снова попадает в кавычки.
+
+Как и в обычном режиме, запись из одного поля считается строкой без разделителя:
+она выводится без изменений, а с -s пропускается.
+*/
+
+func cutCSV(r io.Reader, w *bufio.Writer, cfg *cutConfig) error {
+	// cutCSV читает записи CSV по одной и сразу пишет выбранные поля в w
+	reader := csv.NewReader(r)
+	reader.Comma, _ = utf8.DecodeRuneInString(cfg.delimiter)
+	// Количество полей в записях может различаться, как и количество колонок в строках для cut
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	writer := csv.NewWriter(w)
+	writer.Comma, _ = utf8.DecodeRuneInString(cfg.outputDelimiter())
+
+	header := len(cfg.headerNames) > 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header {
+			if cfg, err = cfg.withHeader(record); err != nil {
+				return err
+			}
+			header = false
+		}
+		if len(record) == 1 {
+			if cfg.separated {
+				continue
+			}
+		} else if cfg.template != nil {
+			// Шаблон задает формат строки целиком, поэтому результат выводится как есть, без экранирования CSV
+			writer.Flush()
+			w.WriteString(cfg.template.execute(record))
+			w.WriteByte('\n')
+			continue
+		} else {
+			record = selectFields(record, cfg)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func cutString(t *testing.T, input string, cfg *cutConfig) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	err := cutReader(strings.NewReader(input), w, cfg)
+	w.Flush()
+	return buf.String(), err
+}
+
+func TestCutCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		delimiter string
+		fields    string
+		names     []string
+		separated bool
+		expected  string
+	}{
+		{
+			name:      "разделитель внутри кавычек",
+			input:     "id,name,amount\n1,\"Иванов, Иван\",100\n",
+			delimiter: ",",
+			fields:    "2",
+			expected:  "name\n\"Иванов, Иван\"\n",
+		},
+		{
+			name:      "кавычки внутри поля",
+			input:     "1,\"он сказал \"\"привет\"\"\",x\n",
+			delimiter: ",",
+			fields:    "2-3",
+			expected:  "\"он сказал \"\"привет\"\"\",x\n",
+		},
+		{
+			name:      "перевод строки внутри поля",
+			input:     "1,\"две\nстроки\",3\n4,5,6\n",
+			delimiter: ",",
+			fields:    "2",
+			expected:  "\"две\nстроки\"\n5\n",
+		},
+		{
+			name:      "TSV",
+			input:     "a\t\"b\tc\"\td\n",
+			delimiter: "\t",
+			fields:    "2",
+			expected:  "\"b\tc\"\n",
+		},
+		{
+			name:      "выбор по именам из заголовка",
+			input:     "user_id,name,amount\n7,\"Петров, Петр\",250\n",
+			delimiter: ",",
+			names:     []string{"user_id", "amount"},
+			expected:  "user_id,amount\n7,250\n",
+		},
+		{
+			name:      "запись из одного поля",
+			input:     "a,b\nодно\n",
+			delimiter: ",",
+			fields:    "2",
+			expected:  "b\nодно\n",
+		},
+		{
+			name:      "запись из одного поля с -s",
+			input:     "a,b\nодно\n",
+			delimiter: ",",
+			fields:    "2",
+			separated: true,
+			expected:  "b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &cutConfig{csv: true, delimiter: test.delimiter, headerNames: test.names, separated: test.separated}
+			if test.fields != "" {
+				cfg.fields, _ = parseFieldList(test.fields)
+			}
+			if err := cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			result, err := cutString(t, test.input, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestHeaderNamesWithoutCSV(t *testing.T) {
+	cfg := &cutConfig{delimiter: ":", headerNames: []string{"amount", "user_id"}}
+	result, err := cutString(t, "user_id:name:amount\n1:a:10\n2:b:20\n", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Порядок колонок - как в строке, так же как для -f
+	expected := "user_id:amount\n1:10\n2:20\n"
+	if result != expected {
+		t.Errorf("Ожидалось: %q, получено: %q", expected, result)
+	}
+}
+
+func TestHeaderNameNotFound(t *testing.T) {
+	for _, csvMode := range []bool{false, true} {
+		cfg := &cutConfig{csv: csvMode, delimiter: ",", headerNames: []string{"missing"}}
+		if _, err := cutString(t, "a,b\n1,2\n", cfg); err == nil {
+			t.Errorf("csv=%v: ожидалась ошибка для неизвестной колонки", csvMode)
+		}
+	}
+}
+
+func TestCSVMalformed(t *testing.T) {
+	cfg := &cutConfig{csv: true, delimiter: ",", fields: fieldList{{1, 1}}}
+	if _, err := cutString(t, "a,\"b\n", cfg); err == nil {
+		t.Error("ожидалась ошибка для незакрытой кавычки")
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 /*
@@ -20,81 +25,448 @@ import (
 -d - "delimiter" - использовать другой разделитель
 -s - "separated" - только строки с разделителем
 
+Строки без разделителя выводятся без изменений, если не указан -s.
+Поля, которых нет в строке, пропускаются: для них выводится пустая строка, а не ошибка.
+
+Дополнительно:
+-f принимает список полей, как POSIX cut: номера с 1, через запятую, с диапазонами (1,3-5,7-).
+Поля выводятся в порядке следования в строке и без повторов
+--complement - выводить все поля, кроме перечисленных в -f (или байты и символы для -b и -c)
+-b - "bytes" - выбрать байты, список в том же формате, что и у -f
+-c - "characters" - выбрать символы UTF-8, кириллица не разрезается посреди символа
+-n - вместе с -b не разрезать многобайтовые символы: символ выводится, только если выбраны все его байты
+--output-delimiter - разделитель полей в выводе для -f, по умолчанию совпадает с -d
+--csv - разбирать ввод как CSV (RFC 4180): разделитель внутри кавычек не делит поле, в выводе поля
+экранируются по тем же правилам. Разделитель по умолчанию - запятая, для TSV: --csv -d $'\t'
+-F - выбрать колонки по именам из первой строки (заголовка): -F user_id,amount
+-D - разделитель-регулярное выражение вместо -d: -D '\s+'. Поля в выводе разделяются пробелом или --output-delimiter
+--reorder - выводить поля в порядке списка -f или -F и с повторами: -f 3,1 --reorder выведет третье поле, затем первое
+-T - шаблон вывода вместо списка полей: -T '{3}={1}', {N} - поле с номером N, {{ и }} - фигурные скобки
+Читает STDIN или перечисленные файлы ("-" - тоже STDIN) построчно, не загружая ввод в память целиком
+
 Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 */
 
 type cutConfig struct {
-	fields    int
-	delimiter string
-	separated bool
+	fields     fieldList
+	bytes      fieldList
+	chars      fieldList
+	delimiter  string
+	separated  bool
+	complement bool
+	noSplit    bool
+	// outDelimiter - разделитель полей в выводе, outDelimiterSet - задан ли он явно (может быть пустым)
+	outDelimiter    string
+	outDelimiterSet bool
+	// csv - разбирать ввод как CSV (RFC 4180), headerNames - имена колонок из -F
+	csv         bool
+	headerNames []string
+	// regexDelimiter - разделитель-регулярное выражение из -D, используется вместо -d
+	regexDelimiter *regexp.Regexp
+	// reorder - выводить поля в порядке списка -f, а не в порядке строки
+	reorder  bool
+	template *outputTemplate
 }
 
 func (cfg *cutConfig) parseConfig() {
-	flag.IntVar(&cfg.fields, "f", 0, "выбрать поля (колонки)")
+	flag.Var(&cfg.fields, "f", "выбрать поля (колонки), например 1,3-5,7-")
 	flag.StringVar(&cfg.delimiter, "d", "\t", "использовать другой разделитель")
 	flag.BoolVar(&cfg.separated, "s", false, "только строки с разделителем")
+	flag.Var(&cfg.bytes, "b", "выбрать байты, например 1-4")
+	flag.Var(&cfg.chars, "c", "выбрать символы, например 1-4")
+	flag.BoolVar(&cfg.complement, "complement", false, "выбрать все поля, кроме указанных в -f, -b или -c")
+	flag.BoolVar(&cfg.noSplit, "n", false, "с -b не разрезать многобайтовые символы")
+
+	flag.Func("output-delimiter", "разделитель полей в выводе (по умолчанию как -d)", func(value string) error {
+		cfg.outDelimiter = value
+		cfg.outDelimiterSet = true
+		return nil
+	})
+	flag.BoolVar(&cfg.csv, "csv", false, "разбирать ввод как CSV с кавычками (RFC 4180)")
+	flag.Func("F", "выбрать колонки по именам из заголовка, например user_id,amount", func(value string) error {
+		cfg.headerNames = strings.Split(value, ",")
+		return nil
+	})
+
+	flag.Func("D", "разделитель - регулярное выражение, например '\\s+'", func(value string) error {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return err
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("разделитель %q совпадает с пустой строкой", value)
+		}
+		cfg.regexDelimiter = re
+		return nil
+	})
+	flag.BoolVar(&cfg.reorder, "reorder", false, "выводить поля в порядке, указанном в -f или -F")
+	flag.Func("T", "шаблон вывода, например '{3}={1}'", func(value string) error {
+		tmpl, err := parseTemplate(value)
+		if err != nil {
+			return err
+		}
+		cfg.template = tmpl
+		return nil
+	})
 
 	flag.Parse()
+
+	// В режиме CSV разделитель по умолчанию - запятая, для TSV нужно явно указать -d
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if cfg.csv && !explicit["d"] {
+		cfg.delimiter = ","
+	}
+}
+
+// fieldRange - диапазон номеров полей [from, to], нумерация с 1. to == 0 означает "до конца строки"
+type fieldRange struct {
+	from int
+	to   int
+}
+
+// fieldList - список полей из флага -f. Реализует flag.Value
+type fieldList []fieldRange
+
+func (fl *fieldList) String() string {
+	parts := make([]string, 0, len(*fl))
+	for _, r := range *fl {
+		switch {
+		case r.to == 0:
+			parts = append(parts, fmt.Sprintf("%d-", r.from))
+		case r.from == r.to:
+			parts = append(parts, strconv.Itoa(r.from))
+		default:
+			parts = append(parts, fmt.Sprintf("%d-%d", r.from, r.to))
+		}
+	}
+	return strings.Join(parts, ",")
 }
 
-func readLinesFromFile(fileName, delimiter string) ([][]string, error) {
-	var result [][]string
-	file, err := os.Open(fileName)
+func (fl *fieldList) Set(value string) error {
+	list, err := parseFieldList(value)
 	if err != nil {
-		return result, err
+		return err
 	}
-	defer file.Close()
+	*fl = list
+	return nil
+}
+
+func parseFieldList(value string) (fieldList, error) {
+	// parseFieldList разбирает список вида "1,3-5,7-" или "-3" (с первого по третье поле)
+	var list fieldList
+	for _, part := range strings.Split(value, ",") {
+		if part == "" {
+			return nil, fmt.Errorf("пустой элемент в списке полей %q", value)
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		r := fieldRange{}
+		var err error
+		if from == "" {
+			// "-N" - с первого поля по N-е
+			r.from = 1
+		} else if r.from, err = strconv.Atoi(from); err != nil {
+			return nil, fmt.Errorf("неверный номер поля %q", from)
+		}
+		switch {
+		case !isRange:
+			r.to = r.from
+		case to == "":
+			if from == "" {
+				return nil, errors.New("неверный диапазон \"-\"")
+			}
+			// "N-" - с N-го поля до конца строки
+			r.to = 0
+		default:
+			if r.to, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("неверный номер поля %q", to)
+			}
+		}
+		if r.from < 1 || (isRange && to != "" && r.to < 1) {
+			return nil, errors.New("поля нумеруются с 1")
+		}
+		if r.to != 0 && r.to < r.from {
+			return nil, fmt.Errorf("неверный диапазон %q", part)
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		resLine := strings.Split(scanner.Text(), delimiter)
-		result = append(result, resLine)
+func (fl fieldList) contains(n int) bool {
+	// contains сообщает, входит ли поле с номером n (с 1) в список
+	for _, r := range fl {
+		if n >= r.from && (r.to == 0 || n <= r.to) {
+			return true
+		}
 	}
-	return result, scanner.Err()
+	return false
 }
 
-func getResultByField(columns [][]string, numberField int, separated bool, delimiter string) ([][]string, error) {
-	// getResultByField возвращает [][]string по указанному номеру колонки
-	var result [][]string
-	for _, line := range columns {
-		// Если не нужно выводить колонки без разделителя, то проверяем входит ли delimiter(разделитель) в строку
-		if separated && !strings.Contains(strings.Join(line, delimiter), delimiter) {
-			continue
+func (fl fieldList) ordered(count int) []int {
+	// ordered возвращает индексы (с 0) полей строки из count полей в порядке списка, с повторами:
+	// для "-f 3,1-2,1" это 2, 0, 1, 0. Номера за пределами строки пропускаются
+	result := make([]int, 0, count)
+	for _, r := range fl {
+		to := r.to
+		if to == 0 || to > count {
+			to = count
 		}
-		// Проверка что номер колонки из флага не выходит за пределы слайса
-		if numberField < len(line) {
-			result = append(result, []string{line[numberField]})
-		} else {
-			return nil, errors.New("номер колонки вышел за пределы")
+		for n := r.from; n <= to; n++ {
+			result = append(result, n-1)
 		}
 	}
-	return result, nil
+	return result
 }
 
-func main() {
-	var cfg cutConfig
-	cfg.parseConfig()
-	args := flag.Args()
-	if len(args) < 1 {
-		log.Println("Пропущено название файла")
-		os.Exit(1)
+func (fl fieldList) indexes(count int, complement bool) []int {
+	// indexes возвращает индексы (с 0) выбранных полей строки из count полей.
+	// Как в POSIX cut, поля идут в порядке следования в строке и без повторов, даже для "-f 3,1,1"
+	result := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		if fl.contains(i+1) != complement {
+			result = append(result, i)
+		}
 	}
-	// Название файла из которого необходимо взять данные
-	inputFile := args[len(args)-1]
+	return result
+}
 
-	resultReadLine, err := readLinesFromFile(inputFile, cfg.delimiter)
-	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+func (cfg *cutConfig) validate() error {
+	// validate проверяет, что выбран ровно один режим: -b, -c, -f или -F
+	modes := 0
+	for _, list := range []fieldList{cfg.fields, cfg.bytes, cfg.chars} {
+		if len(list) > 0 {
+			modes++
+		}
+	}
+	if len(cfg.headerNames) > 0 {
+		modes++
+	}
+	if cfg.template != nil {
+		modes++
+	}
+	byFields := len(cfg.fields) > 0 || len(cfg.headerNames) > 0 || cfg.template != nil
+	switch {
+	case modes == 0:
+		return errors.New("нужно указать список байтов, символов или полей: -b, -c, -f, -F или -T")
+	case modes > 1:
+		return errors.New("можно указать только один из списков -b, -c, -f, -F или шаблон -T")
+	case cfg.separated && !byFields:
+		return errors.New("флаг -s имеет смысл только вместе с -f, -F или -T")
+	case cfg.csv && !byFields:
+		return errors.New("флаг --csv работает только с -f, -F или -T")
+	case cfg.regexDelimiter != nil && (!byFields || cfg.csv):
+		return errors.New("флаг -D работает только с -f, -F или -T и без --csv")
+	case cfg.reorder && (len(cfg.fields) == 0 && len(cfg.headerNames) == 0):
+		return errors.New("флаг --reorder работает только с -f или -F")
+	case cfg.reorder && cfg.complement:
+		return errors.New("флаги --reorder и --complement несовместимы")
+	case cfg.regexDelimiter == nil && utf8.RuneCountInString(cfg.delimiter) != 1:
+		// Как в cut(1): пустой или многосимвольный -d - ошибка. С -D флаг -d не используется
+		return errors.New("разделитель -d должен быть одним символом")
+	case cfg.csv && utf8.RuneCountInString(cfg.outputDelimiter()) != 1:
+		return errors.New("в режиме --csv разделитель вывода должен быть одним символом")
+	}
+	for _, name := range cfg.headerNames {
+		if name == "" {
+			return errors.New("пустое имя колонки в -F")
+		}
+	}
+	return nil
+}
+
+func cutBytes(line string, list fieldList, complement, noSplit bool) string {
+	// cutBytes возвращает выбранные байты строки. С noSplit многобайтовый символ
+	// попадает в результат, только если выбраны все его байты
+	selected := list.indexes(len(line), complement)
+	if !noSplit {
+		result := make([]byte, 0, len(selected))
+		for _, idx := range selected {
+			result = append(result, line[idx])
+		}
+		return string(result)
+	}
+
+	isSelected := make([]bool, len(line))
+	for _, idx := range selected {
+		isSelected[idx] = true
 	}
-	// Получаем конечный результат
-	result, err := getResultByField(resultReadLine, cfg.fields, cfg.separated, cfg.delimiter)
+	var result strings.Builder
+	for i := 0; i < len(line); {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		whole := true
+		for j := i; j < i+size; j++ {
+			whole = whole && isSelected[j]
+		}
+		if whole {
+			result.WriteString(line[i : i+size])
+		}
+		i += size
+	}
+	return result.String()
+}
+
+func cutChars(line string, list fieldList, complement bool) string {
+	// cutChars возвращает выбранные символы строки, считая символы UTF-8, а не байты
+	runes := []rune(line)
+	var result strings.Builder
+	for _, idx := range list.indexes(len(runes), complement) {
+		result.WriteRune(runes[idx])
+	}
+	return result.String()
+}
+
+func selectFields(fields []string, cfg *cutConfig) []string {
+	// selectFields возвращает поля строки из списка cfg.fields.
+	// Номера за пределами строки просто пропускаются, как в cut: "-f 5" для строки из трех полей дает пустую строку
+	indexes := cfg.fields.indexes(len(fields), cfg.complement)
+	if cfg.reorder {
+		indexes = cfg.fields.ordered(len(fields))
+	}
+	selected := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		selected = append(selected, fields[idx])
+	}
+	return selected
+}
+
+func cutLine(line string, cfg *cutConfig) (string, bool) {
+	// cutLine возвращает результат для одной строки и признак, нужно ли ее выводить
+	switch {
+	case len(cfg.bytes) > 0:
+		return cutBytes(line, cfg.bytes, cfg.complement, cfg.noSplit), true
+	case len(cfg.chars) > 0:
+		return cutChars(line, cfg.chars, cfg.complement), true
+	}
+	// Строка без разделителя выводится без изменений, а с -s пропускается
+	fields, ok := cfg.split(line)
+	if !ok {
+		return line, !cfg.separated
+	}
+	if cfg.template != nil {
+		return cfg.template.execute(fields), true
+	}
+	return strings.Join(selectFields(fields, cfg), cfg.outputDelimiter()), true
+}
+
+func (cfg *cutConfig) split(line string) ([]string, bool) {
+	// split делит строку на поля по -D или -d. Второе значение - есть ли в строке разделитель
+	if cfg.regexDelimiter != nil {
+		if !cfg.regexDelimiter.MatchString(line) {
+			return nil, false
+		}
+		return cfg.regexDelimiter.Split(line, -1), true
+	}
+	if !strings.Contains(line, cfg.delimiter) {
+		return nil, false
+	}
+	return strings.Split(line, cfg.delimiter), true
+}
+
+func (cfg *cutConfig) outputDelimiter() string {
+	// outputDelimiter - разделитель полей в выводе: --output-delimiter, если задан, иначе входной.
+	// У -D нет одного входного разделителя, поэтому поля разделяются пробелом, как в awk
+	switch {
+	case cfg.outDelimiterSet:
+		return cfg.outDelimiter
+	case cfg.regexDelimiter != nil:
+		return " "
+	}
+	return cfg.delimiter
+}
+
+func (cfg *cutConfig) withHeader(header []string) (*cutConfig, error) {
+	// withHeader возвращает копию cfg, в которой имена колонок из -F заменены их номерами в заголовке.
+	// У каждого файла свой заголовок, поэтому исходный cfg не меняется
+	fields := make(fieldList, 0, len(cfg.headerNames))
+	for _, name := range cfg.headerNames {
+		idx := slices.Index(header, name)
+		if idx < 0 {
+			return nil, fmt.Errorf("колонка %q не найдена в заголовке", name)
+		}
+		fields = append(fields, fieldRange{from: idx + 1, to: idx + 1})
+	}
+	result := *cfg
+	result.fields = fields
+	return &result, nil
+}
+
+func cutReader(r io.Reader, w *bufio.Writer, cfg *cutConfig) error {
+	// cutReader построчно читает r и сразу пишет результат в w, не держа весь ввод в памяти
+	if cfg.csv {
+		return cutCSV(r, w, cfg)
+	}
+	reader := bufio.NewReader(r)
+	header := len(cfg.headerNames) > 0
+	for {
+		line, readErr := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			// С -F первая строка - заголовок, по нему имена колонок превращаются в номера
+			if header {
+				var err error
+				fields, ok := cfg.split(line)
+				if !ok {
+					fields = []string{line}
+				}
+				if cfg, err = cfg.withHeader(fields); err != nil {
+					return err
+				}
+				header = false
+			}
+			if result, ok := cutLine(line, cfg); ok {
+				w.WriteString(result)
+				w.WriteByte('\n')
+			}
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+func cutFile(w *bufio.Writer, cfg *cutConfig, name string) error {
+	// cutFile обрабатывает файл name, "-" означает STDIN
+	if name == "-" {
+		return cutReader(os.Stdin, w, cfg)
+	}
+	file, err := os.Open(name)
 	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return cutReader(file, w, cfg)
+}
+
+func main() {
+	var cfg cutConfig
+	cfg.parseConfig()
+	if err := cfg.validate(); err != nil {
 		log.Println(err)
 		os.Exit(1)
 	}
-	// Вывод результата
-	for _, line := range result {
-		fmt.Println(line)
+	// Без аргументов читаем STDIN
+	inputs := flag.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	exitCode := 0
+	// Как и cut, при ошибке в одном файле продолжаем с остальными, но завершаемся с кодом 1
+	for _, name := range inputs {
+		if err := cutFile(w, &cfg, name); err != nil {
+			w.Flush()
+			log.Println(err)
+			exitCode = 1
+		}
+	}
+	if exitCode != 0 {
+		w.Flush()
+		os.Exit(exitCode)
 	}
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"os"
 	"reflect"
 	"testing"
@@ -22,107 +24,249 @@ func createTestFile(content string) (string, error) {
 	return file.Name(), nil
 }
 
-func TestReadLinesFromFile(t *testing.T) {
+func TestCutFile(t *testing.T) {
 	tests := []struct {
-		name             string
-		content          string
-		delimiter        string
-		expectedReadLine [][]string
-		separated        bool
-		numberField      int
-		expectedResult   [][]string
+		name      string
+		content   string
+		delimiter string
+		separated bool
+		fields    string
+		outDelim  string
+		expected  string
 	}{
 		{
 			name:      "базовый случай",
 			content:   "a\tb\tc\nd\te\tf\n",
 			delimiter: "\t",
-			expectedReadLine: [][]string{
-				{"a", "b", "c"},
-				{"d", "e", "f"},
-			},
-			separated:   false,
-			numberField: 0,
-			expectedResult: [][]string{
-				{"a"},
-				{"d"},
-			},
+			fields:    "1",
+			expected:  "a\nd\n",
 		},
 		{
 			name:      "другой разделитель",
 			content:   "a,b,c\nd,e,f\n",
 			delimiter: ",",
-			expectedReadLine: [][]string{
-				{"a", "b", "c"},
-				{"d", "e", "f"},
-			},
-			separated:   false,
-			numberField: 0,
-			expectedResult: [][]string{
-				{"a"},
-				{"d"},
-			},
+			fields:    "1",
+			expected:  "a\nd\n",
 		},
 		{
 			name:      "Проверка с флагом separated",
 			content:   "a,b,c\nd,e,f\ndef",
 			delimiter: ",",
-			expectedReadLine: [][]string{
-				{"a", "b", "c"},
-				{"d", "e", "f"},
-				{"def"},
-			},
-			separated:   true,
-			numberField: 0,
-			expectedResult: [][]string{
-				{"a"},
-				{"d"},
-			},
+			separated: true,
+			fields:    "1",
+			expected:  "a\nd\n",
 		},
 		{
 			name:      "Проверка без флага separated",
 			content:   "a,b,c\nd,e,f\ndef",
 			delimiter: ",",
-			expectedReadLine: [][]string{
-				{"a", "b", "c"},
-				{"d", "e", "f"},
-				{"def"},
-			},
-			separated:   false,
-			numberField: 0,
-			expectedResult: [][]string{
-				{"a"},
-				{"d"},
-				{"def"},
-			},
+			fields:    "1",
+			expected:  "a\nd\ndef\n",
 		},
 		{
 			name:      "Проверка второго столбца",
 			content:   "a,b,c\nd,e,f\n",
 			delimiter: ",",
-			expectedReadLine: [][]string{
-				{"a", "b", "c"},
-				{"d", "e", "f"},
-			},
-			separated:   true,
-			numberField: 1,
-			expectedResult: [][]string{
-				{"b"},
-				{"e"},
-			},
+			separated: true,
+			fields:    "2",
+			expected:  "b\ne\n",
 		},
+		{
+			name:      "строка без разделителя выводится целиком",
+			content:   "a,b,c\nзаголовок\n",
+			delimiter: ",",
+			fields:    "2",
+			expected:  "b\nзаголовок\n",
+		},
+		{
+			name:      "строка без разделителя пропускается с -s",
+			content:   "a,b,c\nзаголовок\n",
+			delimiter: ",",
+			separated: true,
+			fields:    "2",
+			expected:  "b\n",
+		},
+		{
+			name:      "отсутствующее поле дает пустую строку",
+			content:   "a,b,c\nd,e\nf,g,h\n",
+			delimiter: ",",
+			fields:    "3",
+			expected:  "c\n\nh\n",
+		},
+		{
+			name:      "часть полей отсутствует",
+			content:   "a,b,c,d\ne,f\n",
+			delimiter: ",",
+			fields:    "2,4",
+			expected:  "b,d\nf\n",
+		},
+		{
+			name:      "пустые поля сохраняются",
+			content:   "a,,c\n",
+			delimiter: ",",
+			fields:    "1-3",
+			expected:  "a,,c\n",
+		},
+		{
+			name:      "поля соединяются входным разделителем",
+			content:   "a:b:c\nd:e:f\n",
+			delimiter: ":",
+			fields:    "1,3",
+			expected:  "a:c\nd:f\n",
+		},
+		{
+			name:      "output-delimiter",
+			content:   "a:b:c\nd:e:f\n",
+			delimiter: ":",
+			fields:    "1-",
+			outDelim:  " | ",
+			expected:  "a | b | c\nd | e | f\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileName, err := createTestFile(test.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(fileName)
+			fields, _ := parseFieldList(test.fields)
+			cfg := &cutConfig{fields: fields, delimiter: test.delimiter, separated: test.separated}
+			if test.outDelim != "" {
+				cfg.outDelimiter, cfg.outDelimiterSet = test.outDelim, true
+			}
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			if err := cutFile(w, cfg, fileName); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			if buf.String() != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestParseFieldList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     string
+		expected fieldList
+		wantErr  bool
+	}{
+		{name: "одно поле", list: "2", expected: fieldList{{2, 2}}},
+		{name: "список и диапазоны", list: "1,3-5,7-", expected: fieldList{{1, 1}, {3, 5}, {7, 0}}},
+		{name: "диапазон от начала", list: "-3", expected: fieldList{{1, 3}}},
+		{name: "нулевое поле", list: "0", wantErr: true},
+		{name: "убывающий диапазон", list: "5-3", wantErr: true},
+		{name: "пустой элемент", list: "1,,2", wantErr: true},
+		{name: "не число", list: "a", wantErr: true},
+		{name: "одиночный дефис", list: "-", wantErr: true},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			fileName, _ := createTestFile(test.content)
-			resultReadLine, _ := readLinesFromFile(fileName, test.delimiter)
-			if !reflect.DeepEqual(test.expectedReadLine, resultReadLine) {
-				t.Errorf("1. Ожидалось: %v, получено: %v", test.expectedReadLine, resultReadLine)
+			result, err := parseFieldList(test.list)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Ожидалась ошибка: %v, получено: %v", test.wantErr, err)
 			}
-			result, _ := getResultByField(resultReadLine, test.numberField, test.separated, test.delimiter)
-			if !reflect.DeepEqual(test.expectedResult, result) {
-				t.Errorf("2. Ожидалось: %v, получено: %v", test.expectedResult, result)
+			if !test.wantErr && !reflect.DeepEqual(test.expected, result) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
 			}
 		})
 	}
 }
+
+func TestFieldListSelection(t *testing.T) {
+	line := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	tests := []struct {
+		name       string
+		list       string
+		complement bool
+		expected   []string
+	}{
+		{name: "список с диапазонами", list: "1,3-5,7-", expected: []string{"a", "c", "d", "e", "g", "h"}},
+		{name: "порядок строки и без повторов", list: "3,1,1,2-3", expected: []string{"a", "b", "c"}},
+		{name: "открытый диапазон за концом строки", list: "10-", expected: []string{}},
+		{name: "complement", list: "2-4,8", complement: true, expected: []string{"a", "e", "f", "g"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := parseFieldList(test.list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &cutConfig{fields: fields, delimiter: "\t", complement: test.complement}
+			result := selectFields(line, cfg)
+			if !reflect.DeepEqual(test.expected, result) {
+				t.Errorf("Ожидалось: %v, получено: %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestCutBytesAndChars(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		bytes      string
+		chars      string
+		complement bool
+		noSplit    bool
+		expected   string
+	}{
+		{name: "байты ASCII", line: "abcdef", bytes: "1,3-4", expected: "acd"},
+		{name: "байты с complement", line: "abcdef", bytes: "2-5", complement: true, expected: "af"},
+		{name: "символы кириллицы", line: "привет", chars: "1-3", expected: "при"},
+		{name: "символы открытый диапазон", line: "привет мир", chars: "8-", expected: "мир"},
+		{name: "символы complement", line: "привет", chars: "1,6", complement: true, expected: "риве"},
+		{name: "байты без -n режут символ", line: "при", bytes: "1-3", expected: "п\xd1"},
+		{name: "байты с -n не режут символ", line: "при", bytes: "1-3", noSplit: true, expected: "п"},
+		{name: "байты с -n и смешанной строкой", line: "aпb", bytes: "1-2,4", noSplit: true, expected: "ab"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var result string
+			if test.bytes != "" {
+				list, err := parseFieldList(test.bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result = cutBytes(test.line, list, test.complement, test.noSplit)
+			} else {
+				list, err := parseFieldList(test.chars)
+				if err != nil {
+					t.Fatal(err)
+				}
+				result = cutChars(test.line, list, test.complement)
+			}
+			if result != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateDelimiter(t *testing.T) {
+	tests := []struct {
+		delimiter string
+		valid     bool
+	}{
+		{delimiter: ":", valid: true},
+		{delimiter: "\t", valid: true},
+		{delimiter: "ж", valid: true},
+		{delimiter: "", valid: false},
+		{delimiter: "::", valid: false},
+	}
+
+	for _, test := range tests {
+		cfg := &cutConfig{fields: fieldList{{1, 1}}, delimiter: test.delimiter}
+		if err := cfg.validate(); (err == nil) != test.valid {
+			t.Errorf("-d %q: ожидалась корректность %v, получено: %v", test.delimiter, test.valid, err)
+		}
+	}
+}
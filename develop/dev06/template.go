@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Шаблон вывода (-T), замена коротким однострочникам на awk.
+
+В шаблоне {N} заменяется полем с номером N (с 1), остальной текст выводится как есть:
+-T '{3}={1}' для строки "a:b:c" с -d : выведет "c=a". Фигурные скобки в тексте
+записываются как {{ и }}. Поле, которого нет в строке, заменяется пустой строкой.
+*/
+
+// templatePart - часть шаблона: текст или ссылка на поле, если field > 0
+type templatePart struct {
+	text  string
+	field int
+}
+
+type outputTemplate struct {
+	parts []templatePart
+}
+
+func parseTemplate(value string) (*outputTemplate, error) {
+	tmpl := &outputTemplate{}
+	var text strings.Builder
+	flushText := func() {
+		if text.Len() > 0 {
+			tmpl.parts = append(tmpl.parts, templatePart{text: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "{{"), strings.HasPrefix(value[i:], "}}"):
+			text.WriteByte(value[i])
+			i++
+		case value[i] == '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("незакрытая { в шаблоне %q", value)
+			}
+			ref := value[i+1 : i+end]
+			n, err := strconv.Atoi(ref)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("неверный номер поля {%s} в шаблоне, поля нумеруются с 1", ref)
+			}
+			flushText()
+			tmpl.parts = append(tmpl.parts, templatePart{field: n})
+			i += end
+		case value[i] == '}':
+			return nil, fmt.Errorf("лишняя } в шаблоне %q, для текста используйте }}", value)
+		default:
+			text.WriteByte(value[i])
+		}
+	}
+	flushText()
+	if len(tmpl.parts) == 0 {
+		return nil, errors.New("пустой шаблон")
+	}
+	return tmpl, nil
+}
+
+func (tmpl *outputTemplate) execute(fields []string) string {
+	// execute подставляет поля строки в шаблон
+	var result strings.Builder
+	for _, part := range tmpl.parts {
+		switch {
+		case part.field == 0:
+			result.WriteString(part.text)
+		case part.field <= len(fields):
+			result.WriteString(fields[part.field-1])
+		}
+	}
+	return result.String()
+}
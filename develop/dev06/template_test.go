@@ -0,0 +1,130 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		fields   []string
+		expected string
+	}{
+		{name: "поля и текст", template: "{3}={1}", fields: []string{"a", "b", "c"}, expected: "c=a"},
+		{name: "повтор поля", template: "{1}-{1}", fields: []string{"x"}, expected: "x-x"},
+		{name: "экранирование скобок", template: "{{{2}}}", fields: []string{"a", "b"}, expected: "{b}"},
+		{name: "отсутствующее поле", template: "[{5}]", fields: []string{"a"}, expected: "[]"},
+		{name: "кириллица в тексте", template: "имя: {2}", fields: []string{"1", "Иван"}, expected: "имя: Иван"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tmpl, err := parseTemplate(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := tmpl.execute(test.fields); result != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	for _, template := range []string{"", "{1", "{0}", "{a}", "a}b"} {
+		if _, err := parseTemplate(template); err == nil {
+			t.Errorf("Ожидалась ошибка для шаблона %q", template)
+		}
+	}
+}
+
+func TestRegexDelimiterAndReorder(t *testing.T) {
+	spaces := regexp.MustCompile(`\s+`)
+	tests := []struct {
+		name     string
+		input    string
+		cfg      *cutConfig
+		expected string
+	}{
+		{
+			name:     "пробелы переменной длины",
+			input:    "GET  /index   200\nPOST /api 500\n",
+			cfg:      &cutConfig{regexDelimiter: spaces, fields: fieldList{{1, 1}, {3, 3}}},
+			expected: "GET 200\nPOST 500\n",
+		},
+		{
+			name:     "многосимвольный разделитель",
+			input:    "a::b::c\n",
+			cfg:      &cutConfig{regexDelimiter: regexp.MustCompile(`::`), fields: fieldList{{2, 0}}, outDelimiter: ",", outDelimiterSet: true},
+			expected: "b,c\n",
+		},
+		{
+			name:     "строка без разделителя",
+			input:    "одно\na b\n",
+			cfg:      &cutConfig{regexDelimiter: spaces, fields: fieldList{{2, 2}}},
+			expected: "одно\nb\n",
+		},
+		{
+			name:     "порядок запроса",
+			input:    "a:b:c\n",
+			cfg:      &cutConfig{delimiter: ":", fields: fieldList{{3, 3}, {1, 1}}, reorder: true},
+			expected: "c:a\n",
+		},
+		{
+			name:     "порядок строки по умолчанию",
+			input:    "a:b:c\n",
+			cfg:      &cutConfig{delimiter: ":", fields: fieldList{{3, 3}, {1, 1}}},
+			expected: "a:c\n",
+		},
+		{
+			name:     "порядок запроса с диапазоном и повтором",
+			input:    "a:b:c\n",
+			cfg:      &cutConfig{delimiter: ":", fields: fieldList{{3, 0}, {1, 2}, {1, 1}}, reorder: true},
+			expected: "c:a:b:a\n",
+		},
+		{
+			name:     "шаблон с регулярным разделителем",
+			input:    "user1   10\nuser2 20\n",
+			cfg:      &cutConfig{regexDelimiter: spaces, template: mustTemplate(t, "{2}={1}")},
+			expected: "10=user1\n20=user2\n",
+		},
+		{
+			name:     "шаблон в режиме CSV",
+			input:    "id,name\n1,\"Иванов, Иван\"\n",
+			cfg:      &cutConfig{csv: true, delimiter: ",", template: mustTemplate(t, "{2} ({1})")},
+			expected: "name (id)\nИванов, Иван (1)\n",
+		},
+		{
+			name:     "порядок запроса по именам из заголовка",
+			input:    "id,name,amount\n1,a,10\n",
+			cfg:      &cutConfig{csv: true, delimiter: ",", headerNames: []string{"amount", "id"}, reorder: true},
+			expected: "amount,id\n10,1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.cfg.validate(); err != nil {
+				t.Fatal(err)
+			}
+			result, err := cutString(t, test.input, test.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func mustTemplate(t *testing.T, value string) *outputTemplate {
+	t.Helper()
+	tmpl, err := parseTemplate(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
@@ -0,0 +1,291 @@
+/*
+Package combinator - обобщенные комбинаторы каналов для конвейеров (pipeline).
+
+Все функции принимают context.Context и подчиняются одним правилам жизненного цикла горутин:
+  - каждая функция сразу возвращает выходные каналы, работа идет в горутинах, которые она запускает;
+  - горутины завершаются, когда закончились входные данные или отменен ctx, и перед выходом
+    закрывают свои выходные каналы - после отмены ctx ни одна горутина не остается висеть;
+  - пока ctx не отменен, горутина, которая отправляет значение, ждет читателя. Поэтому вызывающий
+    должен либо дочитать выходной канал до закрытия, либо отменить ctx.
+
+Функции не закрывают входные каналы - это обязанность того, кто в них пишет.
+*/
+package combinator
+
+import (
+	"context"
+	"sync"
+)
+
+// Or возвращает канал, который закрывается, когда любой из channels закрылся или прислал значение,
+// либо когда отменен ctx. Без каналов сигналом служит только отмена ctx.
+//
+// Каналы ожидаются цепочкой горутин: каждая ждет три канала, а остальные передает следующей,
+// всего около len(channels)/3 горутин. Выходной канал закрывается только после того,
+// как завершились все горутины цепочки.
+func Or[T any](ctx context.Context, channels ...<-chan T) <-chan struct{} {
+	out := make(chan struct{})
+	go func() {
+		defer close(out)
+		waitAny(ctx, channels)
+	}()
+	return out
+}
+
+// waitAny блокируется, пока не сработает один из channels или не будет отменен ctx
+func waitAny[T any](ctx context.Context, channels []<-chan T) {
+	switch len(channels) {
+	case 0:
+		<-ctx.Done()
+	case 1:
+		select {
+		case <-channels[0]:
+		case <-ctx.Done():
+		}
+	case 2:
+		select {
+		case <-channels[0]:
+		case <-channels[1]:
+		case <-ctx.Done():
+		}
+	default:
+		// Остальные каналы ждет дочерняя горутина. При выходе она отменяется, и мы дожидаемся ее завершения
+		ctx, cancel := context.WithCancel(ctx)
+		rest := make(chan struct{})
+		go func() {
+			defer close(rest)
+			waitAny(ctx, channels[3:])
+		}()
+		defer func() {
+			cancel()
+			<-rest
+		}()
+
+		select {
+		case <-channels[0]:
+		case <-channels[1]:
+		case <-channels[2]:
+		case <-rest:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// And возвращает канал, который закрывается, когда закрылись все channels, либо когда отменен ctx.
+// Значения из каналов читаются и отбрасываются, чтобы отправители не блокировались.
+// Работает одна горутина, она завершается вместе с закрытием выходного канала.
+func And[T any](ctx context.Context, channels ...<-chan T) <-chan struct{} {
+	out := make(chan struct{})
+	go func() {
+		defer close(out)
+		for _, c := range channels {
+			for open := true; open; {
+				select {
+				case _, open = <-c:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// OrDone пересылает значения из in, пока in не закрыт и ctx не отменен.
+// Позволяет писать "for v := range OrDone(ctx, in)", не проверяя ctx в теле цикла.
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Merge объединяет значения из всех channels в один канал (fan-in). Порядок значений между
+// разными входами не определен. На каждый вход работают две горутины: пересылающая и OrDone,
+// из которого она читает. Выходной канал закрывается, когда завершились все пересылающие:
+// входы закрыты или ctx отменен. Горутины OrDone завершаются по тому же условию.
+func Merge[T any](ctx context.Context, channels ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(channels))
+	for _, c := range channels {
+		go func(c <-chan T) {
+			defer wg.Done()
+			for v := range OrDone(ctx, c) {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(c)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FanOut раздает значения из in между n выходными каналами (fan-out): каждое значение получает
+// ровно один выход. Работает n горутин, по одной на выход: горутина читает из in и ждет своего
+// читателя, поэтому остановившийся читатель держит у себя одно уже взятое из in значение,
+// а остальные значения идут в другие выходы. Горутина закрывает свой выход, когда in закрыт
+// или ctx отменен. При n < 1 возвращается nil.
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	if n < 1 {
+		return nil
+	}
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return
+					}
+					select {
+					case out <- v:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// Tee копирует каждое значение из in в оба выходных канала. Следующее значение читается только
+// после того, как текущее получили оба читателя, поэтому медленный читатель тормозит быстрого.
+// Работают две горутины: пересылающая и OrDone, из которого она читает. Пересылающая закрывает
+// оба выхода, когда in закрыт или ctx отменен, горутина OrDone завершается по тому же условию.
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	out1 := make(chan T)
+	out2 := make(chan T)
+	go func() {
+		defer close(out1)
+		defer close(out2)
+		for v := range OrDone(ctx, in) {
+			// Локальные копии обнуляются после отправки, чтобы каждый выход получил значение один раз
+			o1, o2 := out1, out2
+			for o1 != nil || o2 != nil {
+				select {
+				case o1 <- v:
+					o1 = nil
+				case o2 <- v:
+					o2 = nil
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
+
+// Bridge превращает поток каналов в один канал: значения каждого канала из streams пересылаются
+// по порядку, следующий канал читается после закрытия предыдущего. Пересылающая горутина закрывает
+// выход, когда streams закрыт или ctx отменен. Кроме нее работает горутина OrDone для streams
+// и по одной для текущего канала; они завершаются, когда их вход закрыт или ctx отменен.
+func Bridge[T any](ctx context.Context, streams <-chan (<-chan T)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for stream := range OrDone(ctx, streams) {
+			for v := range OrDone(ctx, stream) {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Take пересылает первые n значений из in и закрывает выход. Выход закрывается раньше,
+// если in закрыт или ctx отменен. Остаток in не читается.
+func Take[T any](ctx context.Context, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Repeat бесконечно отправляет values по кругу. Генератор останавливается и закрывает выход
+// только при отмене ctx, поэтому его обычно ограничивают через Take. Без values выход закрывается сразу.
+func Repeat[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		if len(values) == 0 {
+			return
+		}
+		for {
+			for _, v := range values {
+				select {
+				case out <- v:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// RepeatFn бесконечно отправляет результаты fn, пока ctx не отменен
+func RepeatFn[T any](ctx context.Context, fn func() T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case out <- fn():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
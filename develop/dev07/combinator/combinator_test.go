@@ -0,0 +1,240 @@
+package combinator
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// После всех тестов не должно остаться ни одной горутины комбинаторов
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func generate[T any](values ...T) <-chan T {
+	out := make(chan T, len(values))
+	for _, v := range values {
+		out <- v
+	}
+	close(out)
+	return out
+}
+
+func collect[T any](t *testing.T, c <-chan T) []T {
+	t.Helper()
+	var result []T
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case v, ok := <-c:
+			if !ok {
+				return result
+			}
+			result = append(result, v)
+		case <-timeout:
+			t.Fatal("канал не закрылся за секунду")
+		}
+	}
+}
+
+func waitClosed(t *testing.T, c <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(time.Second):
+		t.Fatal("канал не закрылся за секунду")
+	}
+}
+
+func assertOpen(t *testing.T, c <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-c:
+		t.Fatal("канал закрылся раньше времени")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestOr(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 4, 7, 50} {
+		channels := make([]<-chan int, count)
+		for i := range channels {
+			channels[i] = make(chan int)
+		}
+		trigger := make(chan int)
+		channels[count-1] = trigger
+
+		result := Or(context.Background(), channels...)
+		assertOpen(t, result)
+		close(trigger)
+		waitClosed(t, result)
+	}
+}
+
+func TestOrCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	result := Or(ctx, make(chan int), make(chan int), make(chan int), make(chan int), make(chan int))
+	assertOpen(t, result)
+	cancel()
+	waitClosed(t, result)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	empty := Or[int](ctx)
+	assertOpen(t, empty)
+	cancel()
+	waitClosed(t, empty)
+}
+
+func TestAnd(t *testing.T) {
+	a, b := make(chan string), make(chan string)
+	result := And(context.Background(), a, b)
+	// Значения вычитываются, отправитель не блокируется
+	a <- "значение"
+	close(a)
+	assertOpen(t, result)
+	close(b)
+	waitClosed(t, result)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result = And(ctx, make(chan string))
+	cancel()
+	waitClosed(t, result)
+}
+
+func TestOrDone(t *testing.T) {
+	if result := collect(t, OrDone(context.Background(), generate(1, 2, 3))); !slices.Equal(result, []int{1, 2, 3}) {
+		t.Errorf("Ожидалось: %v, получено: %v", []int{1, 2, 3}, result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := OrDone(ctx, make(chan int))
+	cancel()
+	if result := collect(t, out); len(result) != 0 {
+		t.Errorf("Ожидалось: пустой результат, получено: %v", result)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	result := collect(t, Merge(context.Background(), generate(1, 2), generate(3), generate(4, 5, 6)))
+	sort.Ints(result)
+	if expected := []int{1, 2, 3, 4, 5, 6}; !slices.Equal(result, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, result)
+	}
+	if result := collect(t, Merge[int](context.Background())); len(result) != 0 {
+		t.Errorf("Ожидалось: пустой результат, получено: %v", result)
+	}
+}
+
+func TestMergeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := Merge(ctx, Repeat(ctx, 1), Repeat(ctx, 2))
+	<-out
+	// Читатель ушел, не дочитав: отмена ctx должна завершить все горутины
+	cancel()
+	collect(t, out)
+}
+
+func TestFanOut(t *testing.T) {
+	outs := FanOut(context.Background(), generate(1, 2, 3, 4, 5, 6, 7, 8), 3)
+	if len(outs) != 3 {
+		t.Fatalf("Ожидалось: 3 выхода, получено: %d", len(outs))
+	}
+	result := collect(t, Merge(context.Background(), outs...))
+	sort.Ints(result)
+	if expected := []int{1, 2, 3, 4, 5, 6, 7, 8}; !slices.Equal(result, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, result)
+	}
+	if FanOut(context.Background(), generate(1), 0) != nil {
+		t.Error("Ожидалось: nil при n < 1")
+	}
+}
+
+func TestFanOutCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	outs := FanOut(ctx, generate(1, 2, 3, 4, 5, 6, 7, 8), 2)
+	// Первый выход никто не читает: он держит не больше одного значения, остальные идут во второй
+	if result := collect(t, outs[1]); len(result) < 7 {
+		t.Errorf("Ожидалось не меньше 7 значений, получено: %v", result)
+	}
+	// Отмена ctx закрывает остановившийся выход
+	cancel()
+	collect(t, outs[0])
+}
+
+func TestTee(t *testing.T) {
+	out1, out2 := Tee(context.Background(), generate("a", "b", "c"))
+	var first, second []string
+	for out1 != nil || out2 != nil {
+		select {
+		case v, ok := <-out1:
+			if !ok {
+				out1 = nil
+				continue
+			}
+			first = append(first, v)
+		case v, ok := <-out2:
+			if !ok {
+				out2 = nil
+				continue
+			}
+			second = append(second, v)
+		case <-time.After(time.Second):
+			t.Fatal("Tee не закрыл выходы")
+		}
+	}
+	expected := []string{"a", "b", "c"}
+	if !slices.Equal(first, expected) || !slices.Equal(second, expected) {
+		t.Errorf("Ожидалось: %v в обоих выходах, получено: %v и %v", expected, first, second)
+	}
+}
+
+func TestTeeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out1, out2 := Tee(ctx, Repeat(ctx, 1))
+	<-out1
+	// Второй выход никто не читает: без отмены горутина Tee ждала бы вечно
+	cancel()
+	collect(t, out1)
+	collect(t, out2)
+}
+
+func TestBridge(t *testing.T) {
+	streams := make(chan (<-chan int), 3)
+	streams <- generate(1, 2)
+	streams <- generate[int]()
+	streams <- generate(3)
+	close(streams)
+
+	result := collect(t, Bridge(context.Background(), streams))
+	if expected := []int{1, 2, 3}; !slices.Equal(result, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, result)
+	}
+}
+
+func TestTakeRepeat(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	result := collect(t, Take(ctx, Repeat(ctx, "a", "b"), 5))
+	if expected := []string{"a", "b", "a", "b", "a"}; !slices.Equal(result, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, result)
+	}
+
+	counter := 0
+	numbers := collect(t, Take(ctx, RepeatFn(ctx, func() int { counter++; return counter }), 3))
+	if expected := []int{1, 2, 3}; !slices.Equal(numbers, expected) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, numbers)
+	}
+
+	// Take закрывает выход раньше, если вход закончился
+	if result := collect(t, Take(ctx, generate(1), 5)); !slices.Equal(result, []int{1}) {
+		t.Errorf("Ожидалось: %v, получено: %v", []int{1}, result)
+	}
+	if result := collect(t, Repeat[int](ctx)); len(result) != 0 {
+		t.Errorf("Ожидалось: пустой результат, получено: %v", result)
+	}
+}
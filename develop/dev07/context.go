@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+Интеграция or с context.Context.
+
+withDone превращает набор done каналов в производный контекст, mergeContexts объединяет
+несколько контекстов в один, который отменяется по первому из них. В обоих случаях
+context.Cause сообщает, какой именно вход сработал: *signalError или *contextError с его номером.
+
+Все горутины завершаются вместе с отменой полученного контекста, поэтому cancel нужно вызывать всегда,
+как и для context.WithCancel.
+*/
+
+// signalError - причина отмены контекста из withDone: сработал done канал с номером index
+type signalError struct {
+	index int
+}
+
+func (e *signalError) Error() string {
+	return fmt.Sprintf("сработал done канал №%d", e.index)
+}
+
+// contextError - причина отмены контекста из mergeContexts: отменен контекст с номером index
+type contextError struct {
+	index int
+	cause error
+}
+
+func (e *contextError) Error() string {
+	return fmt.Sprintf("отменен контекст №%d: %v", e.index, e.cause)
+}
+
+func (e *contextError) Unwrap() error {
+	return e.cause
+}
+
+// doneOf приводит ctx.Done() к типу, который принимает or. Горутина завершается,
+// когда закрывается done или stop
+func doneOf(done, stop <-chan struct{}) <-chan interface{} {
+	c := make(chan interface{})
+	go func() {
+		defer close(c)
+		select {
+		case <-done:
+		case <-stop:
+		}
+	}()
+	return c
+}
+
+func withDone(parent context.Context, channels ...<-chan interface{}) (context.Context, context.CancelFunc) {
+	// withDone возвращает контекст, который отменяется, когда срабатывает (закрывается или передает
+	// значение) любой из channels, отменяется parent или вызывается cancel. Причина - *signalError
+	// с номером канала
+	ctx, cancel := context.WithCancelCause(parent)
+	// У каждого канала своя горутина, знающая его номер: канал может сработать, передав значение,
+	// а не закрывшись, и тогда повторно прочитать его, чтобы узнать номер, уже нельзя.
+	// Если каналы сработали почти одновременно, причиной станет тот, чья горутина успела первой
+	for i, c := range channels {
+		go func() {
+			select {
+			case <-c:
+				cancel(&signalError{index: i})
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+func mergeContexts(contexts ...context.Context) (context.Context, context.CancelFunc) {
+	// mergeContexts возвращает контекст, который отменяется, как только отменен любой из contexts.
+	// Причина - *contextError с номером контекста, errors.Is видит исходную причину.
+	// Значения (Value) берутся из первого контекста, а его срок не наследуется напрямую:
+	// по истечении любого срока контекст отменится с причиной context.DeadlineExceeded
+	parent := context.Background()
+	if len(contexts) > 0 {
+		parent = context.WithoutCancel(contexts[0])
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+
+	channels := make([]<-chan interface{}, 0, len(contexts)+1)
+	for _, c := range contexts {
+		channels = append(channels, doneOf(c.Done(), ctx.Done()))
+	}
+	channels = append(channels, doneOf(ctx.Done(), nil))
+
+	go func() {
+		<-or(channels...)
+		for i, c := range contexts {
+			if c.Err() != nil {
+				cancel(&contextError{index: i, cause: context.Cause(c)})
+				return
+			}
+		}
+	}()
+	return ctx, func() { cancel(context.Canceled) }
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func waitDone(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("контекст не отменен за секунду")
+	}
+}
+
+func TestWithDone(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	trigger := make(chan interface{})
+	ctx, cancel := withDone(context.Background(), never(), never(), trigger, never())
+	defer cancel()
+
+	if ctx.Err() != nil {
+		t.Fatal("контекст отменен до сигнала")
+	}
+	close(trigger)
+	waitDone(t, ctx)
+
+	var signal *signalError
+	if !errors.As(context.Cause(ctx), &signal) || signal.index != 2 {
+		t.Errorf("Ожидалось: сработал канал №2, получено: %v", context.Cause(ctx))
+	}
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Ожидалось: %v, получено: %v", context.Canceled, ctx.Err())
+	}
+}
+
+func TestWithDoneValueSignal(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	// Канал срабатывает переданным значением, а не закрытием
+	trigger := make(chan interface{})
+	ctx, cancel := withDone(context.Background(), never(), trigger)
+	defer cancel()
+
+	trigger <- "сигнал"
+	waitDone(t, ctx)
+	var signal *signalError
+	if !errors.As(context.Cause(ctx), &signal) || signal.index != 1 {
+		t.Errorf("Ожидалось: сработал канал №1, получено: %v", context.Cause(ctx))
+	}
+}
+
+func TestWithDoneParentAndCancel(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	parent, cancelParent := context.WithCancelCause(context.Background())
+	parentErr := errors.New("остановка сервера")
+	ctx, cancel := withDone(parent, never(), never())
+	defer cancel()
+	cancelParent(parentErr)
+	waitDone(t, ctx)
+	if cause := context.Cause(ctx); !errors.Is(cause, parentErr) {
+		t.Errorf("Ожидалось: %v, получено: %v", parentErr, cause)
+	}
+
+	// cancel без сигнала завершает все горутины
+	ctx, cancel = withDone(context.Background(), never(), never(), never())
+	cancel()
+	waitDone(t, ctx)
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		t.Errorf("Ожидалось: %v, получено: %v", context.Canceled, cause)
+	}
+}
+
+func TestMergeContexts(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	type key struct{}
+	first := context.WithValue(context.Background(), key{}, "значение")
+	second, cancelSecond := context.WithCancelCause(context.Background())
+	third, cancelThird := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelThird()
+
+	ctx, cancel := mergeContexts(first, second, third)
+	defer cancel()
+	if ctx.Value(key{}) != "значение" {
+		t.Errorf("Ожидалось: значение из первого контекста, получено: %v", ctx.Value(key{}))
+	}
+
+	reason := errors.New("клиент отключился")
+	cancelSecond(reason)
+	waitDone(t, ctx)
+
+	var fired *contextError
+	cause := context.Cause(ctx)
+	if !errors.As(cause, &fired) || fired.index != 1 {
+		t.Errorf("Ожидалось: отменен контекст №1, получено: %v", cause)
+	}
+	if !errors.Is(cause, reason) {
+		t.Errorf("Ожидалось: причина %v, получено: %v", reason, cause)
+	}
+}
+
+func TestMergeContextsDeadline(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	slow, cancelSlow := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelSlow()
+	fast, cancelFast := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelFast()
+
+	ctx, cancel := mergeContexts(slow, fast)
+	defer cancel()
+	waitDone(t, ctx)
+	if cause := context.Cause(ctx); !errors.Is(cause, context.DeadlineExceeded) {
+		t.Errorf("Ожидалось: %v, получено: %v", context.DeadlineExceeded, cause)
+	}
+}
+
+func TestMergeContextsCancel(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	ctx, cancel := mergeContexts(context.Background(), context.Background())
+	cancel()
+	waitDone(t, ctx)
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		t.Errorf("Ожидалось: %v, получено: %v", context.Canceled, cause)
+	}
+}
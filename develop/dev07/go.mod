@@ -1,3 +1,5 @@
 module dev07
 
 go 1.22.3
+
+require go.uber.org/goleak v1.3.0
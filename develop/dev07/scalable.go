@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+)
+
+/*
+or для тысяч каналов.
+
+Рекурсивный or держит по горутине на каждые два-три канала: для 10 000 входов это около
+5 000 горутин со своими стеками, а копирование хвоста на каждом уровне дает квадратичную память. orChunked делит входы на блоки по orChunkSize каналов
+и ждет каждый блок одним вызовом reflect.Select, поэтому горутин всего len/orChunkSize.
+reflect.Select медленнее обычного select, но вызывается один раз на блок, так что
+цена остается линейной от числа каналов и платится при создании, а не при сигнале.
+
+Сравнение с наивной версией (горутина на канал) и рекурсивной - в бенчмарке BenchmarkOr:
+количество горутин, память стеков и задержка от закрытия канала до сигнала.
+	go test -run xxx -bench Or -benchtime 20x
+*/
+
+const (
+	// orRecursiveLimit - до скольких каналов or использует рекурсивное объединение
+	orRecursiveLimit = 64
+	// orChunkSize - сколько каналов ждет одна горутина orChunked
+	orChunkSize = 1024
+)
+
+func orChunked(inputChannels ...<-chan interface{}) <-chan interface{} {
+	// orChunked возвращает канал, который закрывается, когда сработал любой из входов.
+	// Первым case в каждом блоке стоит сам выходной канал: после сигнала из одного блока
+	// он закрывается, и горутины остальных блоков завершаются
+	if len(inputChannels) == 0 {
+		return nil
+	}
+	orDone := make(chan interface{})
+	var once sync.Once
+	for start := 0; start < len(inputChannels); start += orChunkSize {
+		chunk := inputChannels[start:min(start+orChunkSize, len(inputChannels))]
+		cases := make([]reflect.SelectCase, 0, len(chunk)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(orDone)})
+		for _, c := range chunk {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)})
+		}
+		go func() {
+			if chosen, _, _ := reflect.Select(cases); chosen > 0 {
+				once.Do(func() { close(orDone) })
+			}
+		}()
+	}
+	return orDone
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// orNaive - наивная версия для сравнения в бенчмарках: по горутине на каждый канал
+func orNaive(inputChannels ...<-chan interface{}) <-chan interface{} {
+	orDone := make(chan interface{})
+	var once sync.Once
+	for _, c := range inputChannels {
+		go func(c <-chan interface{}) {
+			select {
+			case <-c:
+				once.Do(func() { close(orDone) })
+			case <-orDone:
+			}
+		}(c)
+	}
+	return orDone
+}
+
+func manyChannels(n int) ([]<-chan interface{}, []chan interface{}) {
+	channels := make([]<-chan interface{}, n)
+	raw := make([]chan interface{}, n)
+	for i := range channels {
+		raw[i] = make(chan interface{})
+		channels[i] = raw[i]
+	}
+	return channels, raw
+}
+
+func TestOrChunked(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	const n = 10000
+	for _, pos := range []int{0, orChunkSize - 1, orChunkSize, n / 2, n - 1} {
+		channels, raw := manyChannels(n)
+		result := or(channels...)
+		select {
+		case <-result:
+			t.Fatal("канал закрылся до сигнала")
+		default:
+		}
+		close(raw[pos])
+		waitClosed(t, result, time.Second)
+	}
+}
+
+func TestOrChunkedGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	const n = 10000
+	channels, raw := manyChannels(n)
+	before := runtime.NumGoroutine()
+	result := or(channels...)
+	started := runtime.NumGoroutine() - before
+	if limit := n/orChunkSize + 1; started > limit {
+		t.Errorf("Ожидалось: не больше %d горутин, получено: %d", limit, started)
+	}
+	close(raw[n-1])
+	waitClosed(t, result, time.Second)
+}
+
+func TestOrChunkedManySignals(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	// Одновременный сигнал из нескольких блоков не должен закрыть канал дважды
+	channels, raw := manyChannels(5 * orChunkSize)
+	result := orChunked(channels...)
+	for i := 0; i < len(raw); i += orChunkSize {
+		close(raw[i])
+	}
+	waitClosed(t, result, time.Second)
+}
+
+func BenchmarkOr(b *testing.B) {
+	implementations := []struct {
+		name string
+		or   func(...<-chan interface{}) <-chan interface{}
+	}{
+		{"naive", orNaive},
+		{"recursive", orRecursive},
+		{"chunked", orChunked},
+	}
+	for _, n := range []int{100, 1000, 10000} {
+		for _, impl := range implementations {
+			b.Run(fmt.Sprintf("%s/n=%d", impl.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				var goroutines int
+				var stack uint64
+				var latency time.Duration
+				var stats runtime.MemStats
+				for i := 0; i < b.N; i++ {
+					channels, raw := manyChannels(n)
+					b.StopTimer()
+					// Горутины прошлой итерации должны завершиться, иначе они исказят подсчет
+					settle()
+					before := runtime.NumGoroutine()
+					runtime.ReadMemStats(&stats)
+					stackBefore := stats.StackInuse
+					b.StartTimer()
+
+					result := impl.or(channels...)
+					// Рекурсивная версия запускает горутины постепенно, ждем, пока запустятся все
+					settle()
+
+					b.StopTimer()
+					goroutines += runtime.NumGoroutine() - before
+					runtime.ReadMemStats(&stats)
+					stack += stats.StackInuse - min(stackBefore, stats.StackInuse)
+					b.StartTimer()
+
+					// Задержка - от закрытия последнего канала до закрытия результата
+					start := time.Now()
+					close(raw[n-1])
+					<-result
+					latency += time.Since(start)
+				}
+				b.ReportMetric(float64(goroutines)/float64(b.N), "goroutines/op")
+				b.ReportMetric(float64(stack)/float64(b.N), "stack-B/op")
+				b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N), "ns-latency/op")
+			})
+		}
+	}
+}
+
+// settle уступает процессор, пока количество горутин не перестанет меняться
+func settle() {
+	for last, stable := -1, 0; stable < 3; {
+		runtime.Gosched()
+		if n := runtime.NumGoroutine(); n == last {
+			stable++
+		} else {
+			last, stable = n, 0
+		}
+	}
+}
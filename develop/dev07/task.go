@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -37,27 +36,61 @@ start := time.Now()
 )
 
 fmt.Printf(“fone after %v”, time.Since(start))
+
+Дополнительно:
+Пакет combinator - обобщенные комбинаторы каналов с отменой через context:
+Or, And, Merge, FanOut, Tee, Bridge, OrDone, Take, Repeat.
+withDone и mergeContexts (context.go) строят на or производный context.Context,
+context.Cause сообщает, какой вход сработал.
 */
 
-// inputChannels - слайс каналов
+// inputChannels - слайс done каналов
 func or(inputChannels ...<-chan interface{}) <-chan interface{} {
-	// or позволяет блокировать программу возвращаемым каналом chSignal до получения сигнала из всех
-	// переданных в функцию каналов. Может быть полезна для синхронизации горутин.
-	chSignal := make(chan interface{})
-	defer close(chSignal)
+	// or возвращает канал, который закрывается, как только закроется (или пришлет значение) любой
+	// из входных каналов. Функция не блокирует вызывающего: ожидание идет в отдельной горутине.
+	// Для небольшого числа каналов используется рекурсивное объединение, для тысяч - orChunked
+	if len(inputChannels) > orRecursiveLimit {
+		return orChunked(inputChannels...)
+	}
+	return orRecursive(inputChannels...)
+}
 
-	var wg sync.WaitGroup
-	for _, channel := range inputChannels {
-		wg.Add(1)
-		go func(ch <-chan interface{}) {
-			for i := range ch {
-				chSignal <- i
-			}
-			wg.Done()
-		}(channel)
+func orRecursive(inputChannels ...<-chan interface{}) <-chan interface{} {
+	// Каналы объединяются рекурсивно: горутина ждет первые три канала и or от остальных,
+	// к которым добавлен собственный выходной канал. Когда срабатывает любой канал, выходной канал
+	// закрывается, и это завершает горутины всех уровней рекурсии, поэтому после сигнала
+	// горутины не остаются висеть. Пока ни один канал не сработал, горутины ждут - это ожидаемо.
+	switch len(inputChannels) {
+	case 0:
+		// Без каналов сигнала не будет никогда: чтение из nil канала блокируется навсегда
+		return nil
+	case 1:
+		return inputChannels[0]
 	}
-	wg.Wait()
-	return chSignal
+
+	orDone := make(chan interface{})
+	go func() {
+		defer close(orDone)
+		switch len(inputChannels) {
+		case 2:
+			select {
+			case <-inputChannels[0]:
+			case <-inputChannels[1]:
+			}
+		default:
+			// Копия хвоста, чтобы append не перезаписал массив, переданный вызывающим
+			rest := make([]<-chan interface{}, 0, len(inputChannels)-2)
+			rest = append(rest, inputChannels[3:]...)
+			rest = append(rest, orDone)
+			select {
+			case <-inputChannels[0]:
+			case <-inputChannels[1]:
+			case <-inputChannels[2]:
+			case <-orRecursive(rest...):
+			}
+		}
+	}()
+	return orDone
 }
 
 func main() {
@@ -72,13 +105,11 @@ func main() {
 
 	start := time.Now()
 	<-or(
-		// sig(2*time.Hour),
-		// sig(5*time.Minute),
+		sig(2*time.Hour),
+		sig(5*time.Minute),
 		sig(1*time.Second),
-		sig(4*time.Second),
-		sig(9*time.Second),
-		// sig(1*time.Hour),
-		// sig(1*time.Minute),
+		sig(1*time.Hour),
+		sig(1*time.Minute),
 	)
-	fmt.Printf("done after %v", time.Since(start))
+	fmt.Printf("done after %v\n", time.Since(start))
 }
@@ -1 +1,111 @@
 package main
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// never возвращает канал, который никогда не закрывается
+func never() <-chan interface{} {
+	return make(chan interface{})
+}
+
+func closed() <-chan interface{} {
+	c := make(chan interface{})
+	close(c)
+	return c
+}
+
+func waitClosed(t *testing.T, c <-chan interface{}, timeout time.Duration) {
+	t.Helper()
+	select {
+	case <-c:
+	case <-time.After(timeout):
+		t.Fatalf("канал не закрылся за %v", timeout)
+	}
+}
+
+func TestOrFirstClosed(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	for _, count := range []int{1, 2, 3, 4, 5, 10, 100} {
+		// Закрытый канал ставим в разные позиции, чтобы проверить все уровни рекурсии
+		for _, pos := range []int{0, count / 2, count - 1} {
+			channels := make([]<-chan interface{}, count)
+			for i := range channels {
+				channels[i] = never()
+			}
+			channels[pos] = closed()
+			waitClosed(t, or(channels...), time.Second)
+		}
+	}
+}
+
+func TestOrDoesNotWaitForSlowest(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	fast := make(chan interface{})
+	go func() {
+		defer close(fast)
+		time.Sleep(10 * time.Millisecond)
+	}()
+
+	start := time.Now()
+	waitClosed(t, or(never(), fast, never(), never()), time.Second)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Ожидалось: сигнал через ~10ms, получено: %v", elapsed)
+	}
+}
+
+func TestOrNonBlocking(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	trigger := make(chan interface{})
+	// or должен вернуть канал сразу, даже если ни один вход еще не сработал
+	result := or(never(), trigger, never())
+
+	select {
+	case <-result:
+		t.Fatal("канал закрылся до сигнала")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(trigger)
+	waitClosed(t, result, time.Second)
+}
+
+func TestOrValueSignal(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	// Значение во входном канале тоже считается сигналом и не приводит к deadlock
+	c := make(chan interface{}, 1)
+	c <- "сигнал"
+	waitClosed(t, or(never(), never(), c), time.Second)
+}
+
+func TestOrDoesNotModifyArgs(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	trigger := make(chan interface{})
+	channels := make([]<-chan interface{}, 5, 10)
+	for i := range channels {
+		channels[i] = never()
+	}
+	channels[4] = trigger
+	sentinel := never()
+	extended := append(channels, sentinel)
+
+	result := or(channels...)
+	close(trigger)
+	waitClosed(t, result, time.Second)
+	if extended[5] != sentinel {
+		t.Error("or перезаписал массив, переданный вызывающим")
+	}
+}
+
+func TestOrNoChannels(t *testing.T) {
+	if or() != nil {
+		t.Error("Ожидалось: nil канал без входов")
+	}
+}
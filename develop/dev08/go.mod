@@ -2,4 +2,4 @@ module dev08
 
 go 1.22.3
 
-require github.com/mitchellh/go-ps v1.0.0 // indirect
+require github.com/mitchellh/go-ps v1.0.0
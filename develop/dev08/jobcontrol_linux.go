@@ -0,0 +1,96 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+/*
+Работа с группами процессов и терминалом для управления заданиями.
+
+Каждое задание - отдельная группа процессов (Setpgid). В интерактивном режиме группа
+переднего плана получает терминал (tcsetpgrp), поэтому Ctrl+C и Ctrl+Z от терминала
+приходят только ей, а не шеллу.
+*/
+
+const (
+	// pPgid и wStopped - константы waitid(2), которых нет в пакете syscall
+	pPgid    = 2
+	wStopped = 0x2
+)
+
+func setProcessGroup(cmd *exec.Cmd, pg *processGroup) {
+	// setProcessGroup помещает процесс в группу задания. Первый процесс создает группу
+	// со своим PID, остальные входят в нее. Задание переднего плана в интерактивном режиме
+	// сразу получает терминал: это делает дочерний процесс до exec, без гонки с шеллом
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:    true,
+		Pgid:       pg.id(),
+		Foreground: pg.foreground,
+		// Для Foreground Ctty - дескриптор терминала в шелле, до перенаправлений потоков
+		Ctty: int(os.Stdin.Fd()),
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
+
+func takeTerminal(pgid int) {
+	// takeTerminal отдает терминал группе pgid. Шелл в этот момент может быть в фоне, и ядро
+	// остановило бы его сигналом SIGTTOU, поэтому на время вызова SIGTTOU игнорируется
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	pgrp := int32(pgid)
+	syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pgrp)))
+}
+
+func shellGroup() int {
+	return syscall.Getpgrp()
+}
+
+func isStopped(pgid int) bool {
+	// isStopped проверяет без ожидания, остановился ли какой-то процесс группы (Ctrl+Z, SIGSTOP).
+	// waitid с одним WSTOPPED только забирает уведомление об остановке и не освобождает
+	// завершившиеся процессы - их по-прежнему ждет exec.Cmd.Wait. Уведомления забираются все,
+	// чтобы после fg старая остановка другого процесса конвейера не была принята за новую
+	stopped := false
+	for {
+		var info [128]byte
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPgid, uintptr(pgid),
+			uintptr(unsafe.Pointer(&info)), wStopped|syscall.WNOHANG, 0, 0)
+		// si_pid идет после трех int (signo, errno, code) с выравниванием объединения по размеру указателя:
+		// смещение 16 на 64-битных системах и 12 на 32-битных. 0 - ни один процесс не остановился
+		pidOffset := 12 + unsafe.Sizeof(uintptr(0)) - 4
+		if errno != 0 || *(*int32)(unsafe.Pointer(&info[pidOffset])) == 0 {
+			return stopped
+		}
+		stopped = true
+	}
+}
+
+func signalGroup(pgid int, sig os.Signal) error {
+	// signalGroup посылает сигнал всем процессам группы. Для pgid 0 kill(2) послал бы сигнал
+	// группе самого шелла, поэтому задание без запущенных процессов считается несуществующим
+	if pgid <= 0 {
+		return syscall.ESRCH
+	}
+	return syscall.Kill(-pgid, sig.(syscall.Signal))
+}
+
+func continueGroup(pgid int) error {
+	return signalGroup(pgid, syscall.SIGCONT)
+}
+
+func notifyJobSignals(c chan<- os.Signal) {
+	// notifyJobSignals перехватывает сигналы терминала, чтобы они не завершали и не останавливали сам шелл.
+	// Обработчики через signal.Notify, а не signal.Ignore: игнорирование наследовалось бы запущенными командами
+	signal.Notify(c, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP)
+}
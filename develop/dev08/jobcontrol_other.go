@@ -0,0 +1,41 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// На других системах группы процессов и терминал не настраиваются: задания в фоне работают,
+// но Ctrl+Z и передача терминала не поддерживаются
+
+func setProcessGroup(*exec.Cmd, *processGroup) {}
+
+func isTerminal(*os.File) bool {
+	return false
+}
+
+func takeTerminal(int) {}
+
+func shellGroup() int {
+	return 0
+}
+
+func isStopped(int) bool {
+	return false
+}
+
+func signalGroup(int, os.Signal) error {
+	return errors.New("сигналы группам процессов не поддерживаются")
+}
+
+func continueGroup(int) error {
+	return errors.New("продолжение заданий не поддерживается")
+}
+
+func notifyJobSignals(c chan<- os.Signal) {
+	signal.Notify(c, os.Interrupt)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Таблица заданий.
+
+Задание - конвейер переднего плана или список команд, запущенный с &. Фоновое задание
+выполняется в отдельной горутине на копии шелла (как подоболочка bash), его конвейеры
+запускаются по очереди, и у каждого своя группа процессов.
+
+Задание переднего плана попадает в таблицу, только если его остановили (Ctrl+Z): шелл
+раз в stopPollInterval проверяет, не остановилась ли группа, и возвращает управление.
+*/
+
+const (
+	// stopPollInterval - как часто шелл проверяет, не остановлено ли задание переднего плана
+	stopPollInterval = 50 * time.Millisecond
+	// stoppedStatus - $? остановленного задания: 128 + SIGTSTP, как в bash
+	stoppedStatus = 128 + 20
+	// interruptedStatus - $? прерванного по Ctrl+C wait: 128 + SIGINT, как в bash
+	interruptedStatus = 128 + 2
+)
+
+// processGroup - группа процессов конвейера. pgid - PID первого запущенного процесса, 0 - еще нет процессов
+type processGroup struct {
+	pgid       atomic.Int64
+	foreground bool
+}
+
+func (pg *processGroup) id() int {
+	return int(pg.pgid.Load())
+}
+
+func (pg *processGroup) setID(pgid int) {
+	pg.pgid.Store(int64(pgid))
+}
+
+type jobState int
+
+const (
+	jobRunning jobState = iota
+	jobStopped
+)
+
+type job struct {
+	id      int
+	command string
+	// group - группа процессов текущего конвейера задания
+	group atomic.Pointer[processGroup]
+	// state меняет только горутина шелла
+	state jobState
+	// status записывается до закрытия finished
+	status   int
+	finished chan struct{}
+	// started закрывается, когда фоновое задание запустило первый конвейер
+	started     chan struct{}
+	startedOnce sync.Once
+}
+
+func startJob(command string, pg *processGroup, wait func() int) *job {
+	// startJob создает задание для уже запущенного конвейера и ждет его в отдельной горутине
+	j := &job{command: command, finished: make(chan struct{})}
+	j.group.Store(pg)
+	go func() {
+		j.status = wait()
+		close(j.finished)
+	}()
+	return j
+}
+
+func (j *job) pgid() int {
+	if pg := j.group.Load(); pg != nil {
+		return pg.id()
+	}
+	return 0
+}
+
+func (j *job) markStarted() {
+	j.startedOnce.Do(func() { close(j.started) })
+}
+
+func (j *job) done() bool {
+	select {
+	case <-j.finished:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *job) stateText() string {
+	switch {
+	case j.done() && j.status == 0:
+		return "Завершен"
+	case j.done():
+		return "Выход " + strconv.Itoa(j.status)
+	case j.state == jobStopped:
+		return "Остановлен"
+	}
+	return "Выполняется"
+}
+
+func (sh *shell) subshell() *shell {
+	// subshell возвращает копию шелла для фонового задания. Переменные и директория копируются,
+	// таблица заданий у копии своя, пустая
+	return &shell{status: sh.status, vars: sh.vars.clone(), dir: sh.dir}
+}
+
+func (sh *shell) startBackground(item *andOr, std stdio) {
+	// startBackground запускает список команд в фоне. Без терминала фоновое задание не должно
+	// читать ввод шелла, поэтому его stdin - /dev/null, как в bash
+	var devNull *os.File
+	if !sh.interactive {
+		var err error
+		if devNull, err = os.Open(os.DevNull); err == nil {
+			std.in = devNull
+		}
+	}
+	j := &job{command: item.String() + " &", finished: make(chan struct{}), started: make(chan struct{})}
+	sub := sh.subshell()
+	go func() {
+		sub.execAndOr(item, std, j)
+		if devNull != nil {
+			devNull.Close()
+		}
+		j.status = sub.status
+		j.markStarted()
+		close(j.finished)
+	}()
+	// Шелл продолжает, когда у задания уже есть группа процессов: сразу после & ему можно послать kill %n
+	<-j.started
+	sh.addJob(j)
+	sh.status = 0
+	fmt.Fprintf(std.err, "[%d] %s\n", j.id, j.command)
+}
+
+func (sh *shell) addJob(j *job) {
+	// addJob добавляет задание в таблицу с номером на единицу больше последнего
+	if j.id != 0 {
+		return
+	}
+	j.id = 1
+	if n := len(sh.jobs); n > 0 {
+		j.id = sh.jobs[n-1].id + 1
+	}
+	sh.jobs = append(sh.jobs, j)
+}
+
+func (sh *shell) removeJob(j *job) {
+	for i, other := range sh.jobs {
+		if other == j {
+			sh.jobs = append(sh.jobs[:i], sh.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (sh *shell) findJob(spec string) (*job, error) {
+	// findJob ищет задание по %n, %+ или без аргумента - последнее
+	if len(sh.jobs) == 0 {
+		return nil, fmt.Errorf("%s: нет такого задания", spec)
+	}
+	if spec == "" || spec == "%+" || spec == "%%" {
+		return sh.jobs[len(sh.jobs)-1], nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err == nil {
+		for _, j := range sh.jobs {
+			if j.id == id {
+				return j, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: нет такого задания", spec)
+}
+
+func (sh *shell) printJob(w io.Writer, j *job) {
+	mark := " "
+	switch {
+	case len(sh.jobs) > 0 && sh.jobs[len(sh.jobs)-1] == j:
+		mark = "+"
+	case len(sh.jobs) > 1 && sh.jobs[len(sh.jobs)-2] == j:
+		mark = "-"
+	}
+	fmt.Fprintf(w, "[%d]%s  %-12s %s\n", j.id, mark, j.stateText(), j.command)
+}
+
+func (sh *shell) reportJobs(w io.Writer) {
+	// reportJobs сообщает о завершившихся заданиях и убирает их из таблицы
+	for _, j := range append([]*job(nil), sh.jobs...) {
+		if j.done() {
+			sh.printJob(w, j)
+			sh.removeJob(j)
+		}
+	}
+}
+
+func (sh *shell) waitForeground(j *job, std stdio) int {
+	// waitForeground ждет задание переднего плана, пока оно не завершится или не будет остановлено
+	sh.foreground.Store(j)
+	defer sh.foreground.Store(nil)
+	if sh.interactive {
+		// Задание получило терминал при запуске или в fg, после него терминал возвращается шеллу
+		defer takeTerminal(sh.pgid)
+	}
+	ticker := time.NewTicker(stopPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.finished:
+			sh.removeJob(j)
+			return j.status
+		case <-ticker.C:
+			if pgid := j.pgid(); pgid != 0 && isStopped(pgid) {
+				j.state = jobStopped
+				sh.addJob(j)
+				fmt.Fprintln(std.err)
+				sh.printJob(std.err, j)
+				return stoppedStatus
+			}
+		}
+	}
+}
+
+func (sh *shell) forwardSignal(sig os.Signal) {
+	// forwardSignal пересылает сигнал терминала заданию переднего плана. В интерактивном режиме
+	// задание получает его от терминала само, а шелл - нет, так как он не в группе переднего плана.
+	// Без задания переднего плана Ctrl+C прерывает wait, если он сейчас ждет
+	if j := sh.foreground.Load(); j != nil && j.pgid() != 0 {
+		signalGroup(j.pgid(), sig)
+		return
+	}
+	if sig != os.Interrupt {
+		return
+	}
+	select {
+	case sh.interrupts <- struct{}{}:
+	default:
+	}
+}
+
+func jobSpec(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return ""
+}
+
+func jobsCommand(sh *shell, _ []string, std stdio) int {
+	// jobs выводит таблицу заданий. Завершившиеся задания выводятся последний раз и удаляются
+	for _, j := range append([]*job(nil), sh.jobs...) {
+		sh.printJob(std.out, j)
+		if j.done() {
+			sh.removeJob(j)
+		}
+	}
+	return 0
+}
+
+func fg(sh *shell, args []string, std stdio) int {
+	// fg продолжает задание и делает его заданием переднего плана
+	j, err := sh.findJob(jobSpec(args))
+	if err != nil {
+		fmt.Fprintln(std.err, "fg:", err)
+		return 1
+	}
+	fmt.Fprintln(std.out, strings.TrimSuffix(j.command, " &"))
+	if sh.interactive && j.pgid() != 0 {
+		takeTerminal(j.pgid())
+	}
+	if j.state == jobStopped && j.pgid() != 0 {
+		if err := continueGroup(j.pgid()); err != nil {
+			fmt.Fprintln(std.err, "fg:", err)
+			return 1
+		}
+	}
+	j.state = jobRunning
+	return sh.waitForeground(j, std)
+}
+
+func bg(sh *shell, args []string, std stdio) int {
+	// bg продолжает остановленное задание в фоне
+	j, err := sh.findJob(jobSpec(args))
+	if err != nil {
+		fmt.Fprintln(std.err, "bg:", err)
+		return 1
+	}
+	if j.state != jobStopped {
+		fmt.Fprintf(std.err, "bg: задание %d уже выполняется в фоне\n", j.id)
+		return 0
+	}
+	if err := continueGroup(j.pgid()); err != nil {
+		fmt.Fprintln(std.err, "bg:", err)
+		return 1
+	}
+	j.state = jobRunning
+	if !strings.HasSuffix(j.command, " &") {
+		j.command += " &"
+	}
+	fmt.Fprintf(std.out, "[%d]+ %s\n", j.id, j.command)
+	return 0
+}
+
+func waitCommand(sh *shell, args []string, std stdio) int {
+	// wait ждет завершения указанных заданий или всех выполняющихся. Код возврата - код последнего
+	// указанного задания, без аргументов - 0
+	if len(args) == 1 {
+		for _, j := range append([]*job(nil), sh.jobs...) {
+			if j.state == jobStopped {
+				continue
+			}
+			if !sh.waitFinished(j) {
+				return interruptedStatus
+			}
+			sh.removeJob(j)
+		}
+		return 0
+	}
+	status := 0
+	for _, spec := range args[1:] {
+		j, err := sh.findJob(spec)
+		if err != nil {
+			fmt.Fprintln(std.err, "wait:", err)
+			status = 127
+			continue
+		}
+		if j.state == jobStopped {
+			fmt.Fprintf(std.err, "wait: задание %d остановлено\n", j.id)
+			status = stoppedStatus
+			continue
+		}
+		if !sh.waitFinished(j) {
+			return interruptedStatus
+		}
+		sh.removeJob(j)
+		status = j.status
+	}
+	return status
+}
+
+func (sh *shell) waitFinished(j *job) bool {
+	// waitFinished ждет завершения задания. false - ожидание прервано Ctrl+C, задание продолжает выполняться
+	select {
+	case <-j.finished:
+		return true
+	case <-sh.interrupts:
+		return false
+	}
+}
@@ -0,0 +1,178 @@
+//go:build linux
+
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackgroundJobs(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+		errOut   string
+		status   int
+	}{
+		{
+			name:     "фоновая встроенная команда",
+			lines:    []string{"echo фон & wait"},
+			expected: "фон\n",
+			errOut:   "[1] echo фон &\n",
+		},
+		{
+			name:     "список в фоне",
+			lines:    []string{"true && echo a | cat &", "wait", "echo b"},
+			expected: "a\nb\n",
+			errOut:   "[1] true && echo a | cat &\n",
+		},
+		{
+			name:     "jobs и уведомление о завершении",
+			lines:    []string{"sleep 0.3 &", "jobs", "wait", "echo готово"},
+			expected: "[1]+  Выполняется  sleep 0.3 &\nготово\n",
+			errOut:   "[1] sleep 0.3 &\n",
+		},
+		{
+			name:   "kill по номеру задания",
+			lines:  []string{"sleep 5 &", "kill %1", "wait %1"},
+			errOut: "[1] sleep 5 &\n",
+			status: 128 + 9,
+		},
+		{
+			name: "код возврата wait",
+			// На той же строке шелл еще не сообщал о завершении, поэтому задание есть в таблице,
+			// даже если уже завершилось
+			lines:  []string{"sh -c 'exit 3' & wait %1"},
+			errOut: "[1] sh -c exit 3 &\n",
+			status: 3,
+		},
+		{
+			name:   "wait удаляет дождавшееся задание",
+			lines:  []string{"sh -c 'exit 3' & wait", "wait %1"},
+			errOut: "[1] sh -c exit 3 &\nwait: %1: нет такого задания\n",
+			status: 127,
+		},
+		{
+			name:   "нет задания",
+			lines:  []string{"fg"},
+			errOut: "fg: : нет такого задания\n",
+			status: 1,
+		},
+		{
+			name:   "неизвестный номер",
+			lines:  []string{"sleep 0.1 &", "wait %2"},
+			errOut: "[1] sleep 0.1 &\nwait: %2: нет такого задания\n",
+			status: 127,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, errOut, status := runShell(t, tt.lines...)
+			if out != tt.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", tt.expected, out)
+			}
+			if errOut != tt.errOut {
+				t.Errorf("Ожидалось в stderr: %q, получено: %q", tt.errOut, errOut)
+			}
+			if status != tt.status {
+				t.Errorf("Ожидался код %d, получен %d", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestReportFinishedJobs(t *testing.T) {
+	// О завершении задания шелл сообщает перед следующим приглашением
+	_, errOut, _ := runShell(t, "sh -c 'exit 2' &", "sleep 0.3", ":")
+	expected := "[1] sh -c exit 2 &\n[1]+  Выход 2      sh -c exit 2 &\n"
+	if errOut != expected {
+		t.Errorf("Ожидалось: %q, получено: %q", expected, errOut)
+	}
+}
+
+func TestStoppedJob(t *testing.T) {
+	// Задание переднего плана останавливается SIGSTOP, как по Ctrl+Z, и продолжается в фоне через bg
+	input, commands := io.Pipe()
+	var out, errOut lockedBuffer
+	sh := newShell()
+	std := stdio{in: strings.NewReader(""), out: &out, err: &errOut}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sh.run(input, std, "")
+	}()
+
+	io.WriteString(commands, "sleep 5\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if j := sh.foreground.Load(); j != nil && j.pgid() != 0 {
+			syscall.Kill(-j.pgid(), syscall.SIGSTOP)
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("задание переднего плана не запустилось")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	io.WriteString(commands, "echo $?\njobs\nbg\nkill %1\nwait %1\n")
+	commands.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("шелл не завершился за 5 секунд")
+	}
+	expected := "148\n[1]+  Остановлен   sleep 5\n[1]+ sleep 5 &\n"
+	if out.String() != expected {
+		t.Errorf("Ожидалось: %q, получено: %q", expected, out.String())
+	}
+	if !strings.Contains(errOut.String(), "[1]+  Остановлен   sleep 5\n") {
+		t.Errorf("Нет сообщения об остановке: %q", errOut.String())
+	}
+	if sh.status != 128+9 {
+		t.Errorf("Ожидался код %d, получен %d", 128+9, sh.status)
+	}
+}
+
+func TestBackgroundCd(t *testing.T) {
+	// cd в фоновом задании меняет директорию только копии шелла
+	wd, _ := os.Getwd()
+	out, errOut, _ := runShell(t, "cd / &", "wait", "pwd")
+	if out != wd+"\n" {
+		t.Errorf("Ожидалось: %q, получено: %q (%s)", wd+"\n", out, errOut)
+	}
+}
+
+func TestWaitInterrupt(t *testing.T) {
+	// Ctrl+C прерывает wait с кодом 130, а фоновое задание продолжает выполняться
+	var out, errOut lockedBuffer
+	sh := newShell()
+	std := stdio{in: strings.NewReader(""), out: &out, err: &errOut}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sh.run(strings.NewReader("sleep 5 &\nwait\necho $?\njobs\nkill %1\n"), std, "")
+	}()
+
+	// Сигнал до начала ожидания теряется, поэтому посылаем его, пока шелл не завершится
+	deadline := time.After(5 * time.Second)
+	for running := true; running; {
+		sh.forwardSignal(os.Interrupt)
+		select {
+		case <-done:
+			running = false
+		case <-deadline:
+			t.Fatal("wait не прервался за 5 секунд")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	expected := "130\n[1]+  Выполняется  sleep 5 &\n"
+	if out.String() != expected {
+		t.Errorf("Ожидалось: %q, получено: %q (%s)", expected, out.String(), errOut.String())
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Разбор командной строки: лексер превращает строку в токены, парсер строит из них AST.
+
+Поддерживается:
+  - слова с одинарными кавычками (всё буквально), двойными кавычками (работают $ и \) и \ вне кавычек;
+  - подстановки $VAR, ${VAR}, $? и ~ в начале слова. Результат подстановки не делится на слова;
+  - присваивания NAME=значение перед именем команды;
+  - конвейеры cmd1 | cmd2, списки cmd1 ; cmd2, cmd1 && cmd2, cmd1 || cmd2, фоновый запуск cmd &;
+  - перенаправления [n]>файл, [n]>>файл, [n]<файл, [n]>&m, [n]<&m и <<EOF (n и m - 0, 1 или 2);
+  - комментарии # до конца строки.
+
+Тело heredoc идет в следующих строках ввода, поэтому парсер только запоминает разделители
+в commandList.heredocs, а тела дочитывает шелл. Если разделитель в кавычках (<<'EOF'),
+подстановки в теле не выполняются.
+
+AST:
+	commandList - andOr, разделенные ; или & (andOr перед & выполняется в фоне)
+	andOr       - конвейеры, соединенные && и ||
+	pipeline    - команды, соединенные |
+	command     - присваивания, слова и перенаправления простой команды
+*/
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPipe
+	tokenOr
+	tokenAnd
+	tokenSemi
+	tokenAmp
+	tokenRedirect
+)
+
+func (k tokenKind) String() string {
+	return [...]string{"слово", "|", "||", "&&", ";", "&", "перенаправление"}[k]
+}
+
+type redirectKind int
+
+const (
+	// redirectOut - [n]>файл
+	redirectOut redirectKind = iota
+	// redirectAppend - [n]>>файл
+	redirectAppend
+	// redirectIn - [n]<файл
+	redirectIn
+	// redirectDup - [n]>&m или [n]<&m
+	redirectDup
+	// redirectHeredoc - [n]<<РАЗДЕЛИТЕЛЬ
+	redirectHeredoc
+)
+
+// redirect - перенаправление потока команды
+type redirect struct {
+	kind redirectKind
+	fd   int
+	// target - файл или номер дескриптора для redirectDup
+	target word
+	// delimiter и body - разделитель и тело heredoc. Тело с подстановками, если разделитель без кавычек
+	delimiter string
+	body      word
+	quoted    bool
+}
+
+type partKind int
+
+const (
+	// partLiteral - текст как есть
+	partLiteral partKind = iota
+	// partVar - $NAME или ${NAME}
+	partVar
+	// partStatus - $?
+	partStatus
+	// partHome - ~ в начале слова
+	partHome
+)
+
+type wordPart struct {
+	kind partKind
+	text string
+}
+
+// word - слово командной строки до подстановок
+type word struct {
+	parts []wordPart
+	// quoted - в слове были кавычки: такое слово остается аргументом, даже если после подстановок оно пустое
+	quoted bool
+	// assignment - слово начинается с NAME= без кавычек. Присваиванием оно будет только перед именем команды
+	assignment bool
+}
+
+func (w *word) addLiteral(s string) {
+	if n := len(w.parts); n > 0 && w.parts[n-1].kind == partLiteral {
+		w.parts[n-1].text += s
+		return
+	}
+	w.parts = append(w.parts, wordPart{kind: partLiteral, text: s})
+}
+
+type token struct {
+	kind  tokenKind
+	word  word
+	redir *redirect
+}
+
+// syntaxError - ошибка разбора командной строки
+type syntaxError struct {
+	msg string
+}
+
+func (e *syntaxError) Error() string {
+	return "синтаксическая ошибка: " + e.msg
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || r < utf8.RuneSelf && unicode.IsLetter(r)
+}
+
+func isNameRune(r rune) bool {
+	return isNameStart(r) || r >= '0' && r <= '9'
+}
+
+func isOperatorRune(r rune) bool {
+	return r == '|' || r == '&' || r == ';' || r == '<' || r == '>'
+}
+
+// lexer - состояние разбора строки на токены
+type lexer struct {
+	input  []rune
+	pos    int
+	tokens []token
+	// cur - текущее слово, inWord - начато ли оно (пустые кавычки "" тоже начинают слово)
+	cur    word
+	inWord bool
+}
+
+func lex(input string) ([]token, error) {
+	l := &lexer{input: []rune(input)}
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case unicode.IsSpace(r):
+			l.endWord()
+			l.pos++
+		case r == '#' && !l.inWord:
+			// Комментарий до конца строки
+			l.pos = len(l.input)
+		case r == '<' || r == '>':
+			l.redirection()
+		case isOperatorRune(r):
+			l.endWord()
+			l.operator()
+		case r == '\'':
+			if err := l.singleQuoted(); err != nil {
+				return nil, err
+			}
+		case r == '"':
+			if err := l.doubleQuoted(); err != nil {
+				return nil, err
+			}
+		case r == '\\':
+			l.inWord = true
+			l.pos++
+			if l.pos < len(l.input) {
+				l.cur.addLiteral(string(l.input[l.pos]))
+				l.pos++
+			} else {
+				l.cur.addLiteral("\\")
+			}
+		case r == '$':
+			if err := l.dollar(); err != nil {
+				return nil, err
+			}
+		case r == '~' && !l.inWord && l.tildeEnds(l.pos+1):
+			l.inWord = true
+			l.cur.parts = append(l.cur.parts, wordPart{kind: partHome})
+			l.pos++
+		default:
+			if r == '=' && l.assignmentName() {
+				l.cur.assignment = true
+			}
+			l.inWord = true
+			l.cur.addLiteral(string(r))
+			l.pos++
+		}
+	}
+	l.endWord()
+	return l.tokens, nil
+}
+
+func (l *lexer) endWord() {
+	if l.inWord {
+		l.tokens = append(l.tokens, token{kind: tokenWord, word: l.cur})
+	}
+	l.cur = word{}
+	l.inWord = false
+}
+
+func (l *lexer) next(r rune) bool {
+	// next сообщает, что следующий символ после текущего - r
+	return l.pos+1 < len(l.input) && l.input[l.pos+1] == r
+}
+
+func (l *lexer) operator() {
+	r := l.input[l.pos]
+	kind := map[rune]tokenKind{'|': tokenPipe, '&': tokenAmp, ';': tokenSemi}[r]
+	switch {
+	case r == '|' && l.next('|'):
+		kind = tokenOr
+		l.pos++
+	case r == '&' && l.next('&'):
+		kind = tokenAnd
+		l.pos++
+	}
+	l.pos++
+	l.tokens = append(l.tokens, token{kind: kind})
+}
+
+func (l *lexer) redirection() {
+	// redirection разбирает оператор перенаправления. Число без пробела перед ним - номер дескриптора:
+	// в "2>err" это 2, а в "echo 2 >err" 2 - аргумент
+	r := l.input[l.pos]
+	redir := &redirect{kind: redirectOut, fd: 1}
+	if r == '<' {
+		redir.kind, redir.fd = redirectIn, 0
+	}
+	if fd, ok := l.fdPrefix(); ok {
+		redir.fd = fd
+		l.cur = word{}
+		l.inWord = false
+	} else {
+		l.endWord()
+	}
+	switch {
+	case r == '>' && l.next('>'):
+		redir.kind = redirectAppend
+		l.pos++
+	case r == '<' && l.next('<'):
+		redir.kind = redirectHeredoc
+		l.pos++
+	case l.next('&'):
+		redir.kind = redirectDup
+		l.pos++
+	}
+	l.pos++
+	l.tokens = append(l.tokens, token{kind: tokenRedirect, redir: redir})
+}
+
+func (l *lexer) fdPrefix() (int, bool) {
+	// fdPrefix сообщает, является ли текущее слово номером дескриптора перед < или >
+	if !l.inWord || l.cur.quoted || len(l.cur.parts) != 1 || l.cur.parts[0].kind != partLiteral {
+		return 0, false
+	}
+	fd, err := strconv.Atoi(l.cur.parts[0].text)
+	if err != nil || strings.TrimLeft(l.cur.parts[0].text, "0123456789") != "" {
+		return 0, false
+	}
+	return fd, true
+}
+
+func (l *lexer) assignmentName() bool {
+	// assignmentName сообщает, что текущее слово до = - имя переменной без кавычек и подстановок
+	return !l.cur.quoted && !l.cur.assignment && len(l.cur.parts) == 1 &&
+		l.cur.parts[0].kind == partLiteral && validName(l.cur.parts[0].text)
+}
+
+func (l *lexer) tildeEnds(pos int) bool {
+	// ~ раскрывается в домашнюю директорию, только если за ней конец слова или /
+	if pos >= len(l.input) {
+		return true
+	}
+	r := l.input[pos]
+	return r == '/' || unicode.IsSpace(r) || isOperatorRune(r)
+}
+
+func (l *lexer) singleQuoted() error {
+	// В одинарных кавычках все символы буквальные, экранирования нет
+	end := l.pos + 1
+	for end < len(l.input) && l.input[end] != '\'' {
+		end++
+	}
+	if end == len(l.input) {
+		return &syntaxError{msg: "незакрытая кавычка '"}
+	}
+	l.inWord = true
+	l.cur.quoted = true
+	l.cur.addLiteral(string(l.input[l.pos+1 : end]))
+	l.pos = end + 1
+	return nil
+}
+
+func (l *lexer) doubleQuoted() error {
+	// В двойных кавычках работают подстановки через $, а \ экранирует только $ ` " \
+	l.inWord = true
+	l.cur.quoted = true
+	l.pos++
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case r == '"':
+			l.pos++
+			// Пустые кавычки дают пустой литерал, чтобы "" осталось аргументом
+			l.cur.addLiteral("")
+			return nil
+		case r == '\\' && l.pos+1 < len(l.input) && strings.ContainsRune("$`\"\\", l.input[l.pos+1]):
+			l.cur.addLiteral(string(l.input[l.pos+1]))
+			l.pos += 2
+		case r == '$':
+			if err := l.dollar(); err != nil {
+				return err
+			}
+		default:
+			l.cur.addLiteral(string(r))
+			l.pos++
+		}
+	}
+	return &syntaxError{msg: "незакрытая кавычка \""}
+}
+
+func (l *lexer) dollar() error {
+	// dollar разбирает подстановку после $. Если за $ нет имени, это обычный символ
+	l.inWord = true
+	start := l.pos + 1
+	switch {
+	case start < len(l.input) && l.input[start] == '?':
+		l.cur.parts = append(l.cur.parts, wordPart{kind: partStatus})
+		l.pos = start + 1
+	case start < len(l.input) && l.input[start] == '{':
+		end := start + 1
+		for end < len(l.input) && l.input[end] != '}' {
+			end++
+		}
+		if end == len(l.input) {
+			return &syntaxError{msg: "незакрытая ${"}
+		}
+		name := string(l.input[start+1 : end])
+		switch {
+		case name == "?":
+			l.cur.parts = append(l.cur.parts, wordPart{kind: partStatus})
+		case validName(name):
+			l.cur.parts = append(l.cur.parts, wordPart{kind: partVar, text: name})
+		default:
+			return &syntaxError{msg: fmt.Sprintf("неверная подстановка ${%s}", name)}
+		}
+		l.pos = end + 1
+	case start < len(l.input) && isNameStart(l.input[start]):
+		end := start
+		for end < len(l.input) && isNameRune(l.input[end]) {
+			end++
+		}
+		l.cur.parts = append(l.cur.parts, wordPart{kind: partVar, text: string(l.input[start:end])})
+		l.pos = end
+	default:
+		l.cur.addLiteral("$")
+		l.pos++
+	}
+	return nil
+}
+
+func lexHeredocBody(body string) (word, error) {
+	// lexHeredocBody разбирает тело heredoc с разделителем без кавычек: работают подстановки через $,
+	// а \ экранирует только $ ` и \. Кавычки в теле - обычные символы
+	l := &lexer{input: []rune(body)}
+	l.cur.quoted = true
+	l.cur.addLiteral("")
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case r == '\\' && l.pos+1 < len(l.input) && strings.ContainsRune("$`\\", l.input[l.pos+1]):
+			l.cur.addLiteral(string(l.input[l.pos+1]))
+			l.pos += 2
+		case r == '$':
+			if err := l.dollar(); err != nil {
+				return word{}, err
+			}
+		default:
+			l.cur.addLiteral(string(r))
+			l.pos++
+		}
+	}
+	return l.cur, nil
+}
+
+func validName(name string) bool {
+	// validName проверяет имя переменной: буква или _, затем буквы, цифры или _
+	for i, r := range name {
+		if i == 0 && !isNameStart(r) || !isNameRune(r) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// assignment - присваивание NAME=значение перед командой
+type assignment struct {
+	name  string
+	value word
+}
+
+// command - простая команда: присваивания, имя, аргументы и перенаправления
+type command struct {
+	assignments []assignment
+	args        []word
+	redirects   []*redirect
+}
+
+// pipeline - команды, соединенные |
+type pipeline struct {
+	commands []*command
+}
+
+// andOr - конвейеры, соединенные && и ||. ops[i] стоит между pipelines[i] и pipelines[i+1]
+type andOr struct {
+	pipelines  []*pipeline
+	ops        []tokenKind
+	background bool
+}
+
+// commandList - andOr, выполняемые по очереди
+type commandList struct {
+	items []*andOr
+	// heredocs - перенаправления <<, тела которых нужно дочитать из следующих строк, в порядке появления
+	heredocs []*redirect
+}
+
+// parser - рекурсивный спуск по токенам
+type parser struct {
+	tokens   []token
+	pos      int
+	heredocs []*redirect
+}
+
+func parse(input string) (*commandList, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	list.heredocs = p.heredocs
+	return list, nil
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) unexpected() error {
+	// unexpected - ошибка для текущего токена или конца строки
+	tok, ok := p.peek()
+	if !ok {
+		return &syntaxError{msg: "неожиданный конец строки"}
+	}
+	return &syntaxError{msg: fmt.Sprintf("неожиданный токен %q", tok.kind.String())}
+}
+
+func (p *parser) parseList() (*commandList, error) {
+	list := &commandList{}
+	for {
+		if _, ok := p.peek(); !ok {
+			return list, nil
+		}
+		item, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		tok, ok := p.peek()
+		if !ok {
+			return list, nil
+		}
+		if tok.kind != tokenSemi && tok.kind != tokenAmp {
+			return nil, p.unexpected()
+		}
+		item.background = tok.kind == tokenAmp
+		p.pos++
+	}
+}
+
+func (p *parser) parseAndOr() (*andOr, error) {
+	first, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+	result := &andOr{pipelines: []*pipeline{first}}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenAnd && tok.kind != tokenOr {
+			return result, nil
+		}
+		p.pos++
+		next, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		result.ops = append(result.ops, tok.kind)
+		result.pipelines = append(result.pipelines, next)
+	}
+}
+
+func (p *parser) parsePipeline() (*pipeline, error) {
+	result := &pipeline{}
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		result.commands = append(result.commands, cmd)
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenPipe {
+			return result, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseCommand() (*command, error) {
+	cmd := &command{}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenWord && tok.kind != tokenRedirect {
+			break
+		}
+		p.pos++
+		if tok.kind == tokenWord && tok.word.assignment && len(cmd.args) == 0 {
+			cmd.assignments = append(cmd.assignments, splitAssignment(tok.word))
+			continue
+		}
+		if tok.kind == tokenWord {
+			cmd.args = append(cmd.args, tok.word)
+			continue
+		}
+		// За оператором перенаправления должно идти слово: файл, дескриптор или разделитель heredoc
+		target, ok := p.peek()
+		if !ok || target.kind != tokenWord {
+			return nil, p.unexpected()
+		}
+		p.pos++
+		redir := *tok.redir
+		redir.target = target.word
+		if redir.kind == redirectHeredoc {
+			if err := p.heredocDelimiter(&redir); err != nil {
+				return nil, err
+			}
+			p.heredocs = append(p.heredocs, &redir)
+		}
+		cmd.redirects = append(cmd.redirects, &redir)
+	}
+	// Команда из одних перенаправлений или присваиваний допустима: "> файл" создает пустой файл
+	if len(cmd.args) == 0 && len(cmd.redirects) == 0 && len(cmd.assignments) == 0 {
+		return nil, p.unexpected()
+	}
+	return cmd, nil
+}
+
+func splitAssignment(w word) assignment {
+	// splitAssignment делит слово NAME=значение. Имя и = - начало первого литерала, это проверил лексер
+	name, rest, _ := strings.Cut(w.parts[0].text, "=")
+	value := word{quoted: w.quoted}
+	value.addLiteral(rest)
+	value.parts = append(value.parts, w.parts[1:]...)
+	return assignment{name: name, value: value}
+}
+
+func (p *parser) heredocDelimiter(redir *redirect) error {
+	// Разделитель heredoc берется буквально, подстановки в нем не выполняются
+	var delimiter strings.Builder
+	for _, part := range redir.target.parts {
+		if part.kind != partLiteral {
+			return &syntaxError{msg: "разделитель heredoc не может содержать подстановки"}
+		}
+		delimiter.WriteString(part.text)
+	}
+	if delimiter.Len() == 0 {
+		return &syntaxError{msg: "пустой разделитель heredoc"}
+	}
+	redir.delimiter = delimiter.String()
+	redir.quoted = redir.target.quoted
+	return nil
+}
+
+// Методы String восстанавливают текст команды для списка заданий. Кавычки не сохраняются,
+// подстановки выводятся в исходном виде
+
+func (w word) String() string {
+	var result strings.Builder
+	for _, part := range w.parts {
+		switch part.kind {
+		case partLiteral:
+			result.WriteString(part.text)
+		case partVar:
+			result.WriteString("$" + part.text)
+		case partStatus:
+			result.WriteString("$?")
+		case partHome:
+			result.WriteString("~")
+		}
+	}
+	return result.String()
+}
+
+func (r *redirect) String() string {
+	op := map[redirectKind]string{
+		redirectOut: ">", redirectAppend: ">>", redirectIn: "<", redirectDup: ">&", redirectHeredoc: "<<",
+	}[r.kind]
+	if r.kind == redirectDup && r.fd == 0 {
+		op = "<&"
+	}
+	defaultFd := 1
+	if r.kind == redirectIn || r.kind == redirectHeredoc {
+		defaultFd = 0
+	}
+	if r.fd != defaultFd {
+		op = strconv.Itoa(r.fd) + op
+	}
+	return op + r.target.String()
+}
+
+func (c *command) String() string {
+	parts := make([]string, 0, len(c.assignments)+len(c.args)+len(c.redirects))
+	for _, a := range c.assignments {
+		parts = append(parts, a.name+"="+a.value.String())
+	}
+	for _, w := range c.args {
+		parts = append(parts, w.String())
+	}
+	for _, r := range c.redirects {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *pipeline) String() string {
+	parts := make([]string, len(p.commands))
+	for i, c := range p.commands {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (a *andOr) String() string {
+	var result strings.Builder
+	for i, p := range a.pipelines {
+		if i > 0 {
+			result.WriteString(" " + a.ops[i-1].String() + " ")
+		}
+		result.WriteString(p.String())
+	}
+	return result.String()
+}
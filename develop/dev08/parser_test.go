@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:  "слова и операторы",
+			input: "a|b||c&&d;e&",
+			expected: []token{
+				{kind: tokenWord, word: word{parts: []wordPart{{partLiteral, "a"}}}},
+				{kind: tokenPipe},
+				{kind: tokenWord, word: word{parts: []wordPart{{partLiteral, "b"}}}},
+				{kind: tokenOr},
+				{kind: tokenWord, word: word{parts: []wordPart{{partLiteral, "c"}}}},
+				{kind: tokenAnd},
+				{kind: tokenWord, word: word{parts: []wordPart{{partLiteral, "d"}}}},
+				{kind: tokenSemi},
+				{kind: tokenWord, word: word{parts: []wordPart{{partLiteral, "e"}}}},
+				{kind: tokenAmp},
+			},
+		},
+		{
+			name:  "подстановки",
+			input: `x$A"${B}-$?"~`,
+			expected: []token{
+				{kind: tokenWord, word: word{quoted: true, parts: []wordPart{
+					{partLiteral, "x"}, {partVar, "A"}, {partVar, "B"}, {partLiteral, "-"}, {partStatus, ""}, {partLiteral, "~"},
+				}}},
+			},
+		},
+		{
+			name:  "пустые кавычки",
+			input: `'' ""`,
+			expected: []token{
+				{kind: tokenWord, word: word{quoted: true, parts: []wordPart{{partLiteral, ""}}}},
+				{kind: tokenWord, word: word{quoted: true, parts: []wordPart{{partLiteral, ""}}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, err := lex(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(test.expected, tokens) {
+				t.Errorf("Ожидалось: %+v, получено: %+v", test.expected, tokens)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	list, err := parse("a | b && c || d; e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.items) != 2 {
+		t.Fatalf("Ожидалось: 2 элемента списка, получено: %d", len(list.items))
+	}
+	first := list.items[0]
+	if len(first.pipelines) != 3 || len(first.pipelines[0].commands) != 2 {
+		t.Errorf("Ожидалось: 3 конвейера, первый из 2 команд, получено: %+v", first)
+	}
+	if !reflect.DeepEqual(first.ops, []tokenKind{tokenAnd, tokenOr}) {
+		t.Errorf("Ожидалось: [&& ||], получено: %v", first.ops)
+	}
+
+	// Завершающая ; допустима
+	if _, err := parse("echo a;"); err != nil {
+		t.Errorf("Ожидалось: без ошибки, получено: %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"| a", "a |", "a && && b", "a ||", "; a", "a ;;", `echo "abc`, "echo 'abc", "echo ${A", "echo ${1A}", "echo ${}",
+		"echo >", "echo > | cat", "cat <<", "cat <<$X", "cat <<''",
+	} {
+		_, err := parse(input)
+		var syntax *syntaxError
+		if !errors.As(err, &syntax) {
+			t.Errorf("%q: ожидалась синтаксическая ошибка, получено: %v", input, err)
+		}
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	list, err := parse(`cmd 2>&1 >>log 1 < in 3>x <<"EOF"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := list.items[0].pipelines[0].commands[0]
+	if len(cmd.args) != 2 {
+		t.Errorf("Ожидалось: 2 аргумента (cmd и 1), получено: %d", len(cmd.args))
+	}
+	type short struct {
+		kind redirectKind
+		fd   int
+	}
+	var got []short
+	for _, r := range cmd.redirects {
+		got = append(got, short{r.kind, r.fd})
+	}
+	expected := []short{{redirectDup, 2}, {redirectAppend, 1}, {redirectIn, 0}, {redirectOut, 3}, {redirectHeredoc, 0}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Ожидалось: %v, получено: %v", expected, got)
+	}
+	if len(list.heredocs) != 1 || list.heredocs[0].delimiter != "EOF" || !list.heredocs[0].quoted {
+		t.Errorf("Ожидалось: heredoc с разделителем EOF в кавычках, получено: %+v", list.heredocs)
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	tests := []struct {
+		input       string
+		assignments []string
+		args        []string
+	}{
+		{input: "A=1 B=$X cmd C=2", assignments: []string{"A=1", "B=$X"}, args: []string{"cmd", "C=2"}},
+		{input: `A="a b" B=`, assignments: []string{"A=a b", "B="}},
+		{input: `"A=1" cmd`, args: []string{"A=1", "cmd"}},
+		{input: `A\=1 1A=2 $A=3 A-B=4`, args: []string{"A=1", "1A=2", "$A=3", "A-B=4"}},
+		{input: "A=1=2 > f", assignments: []string{"A=1=2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			list, err := parse(test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := list.items[0].pipelines[0].commands[0]
+			var assignments, args []string
+			for _, a := range cmd.assignments {
+				assignments = append(assignments, a.name+"="+a.value.String())
+			}
+			for _, w := range cmd.args {
+				args = append(args, w.String())
+			}
+			if !reflect.DeepEqual(test.assignments, assignments) || !reflect.DeepEqual(test.args, args) {
+				t.Errorf("Ожидалось: %q %q, получено: %q %q", test.assignments, test.args, assignments, args)
+			}
+		})
+	}
+}
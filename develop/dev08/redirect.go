@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+Перенаправления ввода-вывода.
+
+Перенаправления команды применяются слева направо к ее потокам stdin (0), stdout (1) и stderr (2),
+поэтому "cmd > файл 2>&1" пишет оба потока в файл, а "cmd 2>&1 > файл" - только stdout.
+Внешняя команда получает открытый файл как есть, встроенная пишет в него через io.Writer.
+*/
+
+func closeAll(files []io.Closer) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func (std stdio) get(fd int) interface{} {
+	// get возвращает поток с номером fd
+	switch fd {
+	case 0:
+		return std.in
+	case 1:
+		return std.out
+	}
+	return std.err
+}
+
+func (std *stdio) set(fd int, stream interface{}) error {
+	// set заменяет поток с номером fd. Поток должен подходить по направлению: stdin - для чтения, остальные - для записи
+	switch fd {
+	case 0:
+		r, ok := stream.(io.Reader)
+		if !ok {
+			return errors.New("дескриптор 0 открыт только для записи")
+		}
+		std.in = r
+	case 1, 2:
+		w, ok := stream.(io.Writer)
+		if !ok {
+			return fmt.Errorf("дескриптор %d открыт только для чтения", fd)
+		}
+		if fd == 1 {
+			std.out = w
+		} else {
+			std.err = w
+		}
+	}
+	return nil
+}
+
+func checkFd(fd int) error {
+	if fd < 0 || fd > 2 {
+		return fmt.Errorf("%d: поддерживаются только дескрипторы 0, 1 и 2", fd)
+	}
+	return nil
+}
+
+func (sh *shell) applyRedirects(redirects []*redirect, std stdio) (stdio, []io.Closer, error) {
+	// applyRedirects возвращает потоки команды после перенаправлений и открытые файлы,
+	// которые нужно закрыть после ее завершения. При ошибке файлы тоже возвращаются для закрытия
+	var opened []io.Closer
+	for _, redir := range redirects {
+		if err := checkFd(redir.fd); err != nil {
+			return std, opened, err
+		}
+		var stream interface{}
+		switch redir.kind {
+		case redirectHeredoc:
+			body, _ := sh.expandWord(redir.body)
+			stream = strings.NewReader(body)
+		case redirectDup:
+			target, _ := sh.expandWord(redir.target)
+			fd, err := strconv.Atoi(target)
+			if err != nil || strings.TrimLeft(target, "0123456789") != "" {
+				return std, opened, fmt.Errorf("%s: ожидался номер дескриптора", target)
+			}
+			if err := checkFd(fd); err != nil {
+				return std, opened, err
+			}
+			stream = std.get(fd)
+		default:
+			name, ok := sh.expandWord(redir.target)
+			if !ok || name == "" {
+				return std, opened, errors.New("неоднозначное перенаправление")
+			}
+			// "<файл" с fd 1 или 2 и ">файл" с fd 0 не подходят по направлению. Проверяем до открытия,
+			// чтобы не создать и не обрезать файл, если команда все равно не запустится
+			if redir.kind == redirectIn && redir.fd != 0 || redir.kind != redirectIn && redir.fd == 0 {
+				return std, opened, fmt.Errorf("%s: перенаправление %d в неверном направлении", name, redir.fd)
+			}
+			flags := map[redirectKind]int{
+				redirectOut:    os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+				redirectAppend: os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+				redirectIn:     os.O_RDONLY,
+			}[redir.kind]
+			file, err := os.OpenFile(sh.path(name), flags, 0o644)
+			if err != nil {
+				return std, opened, err
+			}
+			opened = append(opened, file)
+			stream = file
+		}
+		if err := std.set(redir.fd, stream); err != nil {
+			return std, opened, err
+		}
+	}
+	return std, opened, nil
+}
+
+func (sh *shell) readHeredocs(heredocs []*redirect) error {
+	// readHeredocs дочитывает тела heredoc из следующих строк ввода, до строки с разделителем
+	for _, redir := range heredocs {
+		var body strings.Builder
+		for {
+			if sh.input == nil {
+				return fmt.Errorf("heredoc: нет строк для тела до разделителя %q", redir.delimiter)
+			}
+			line, err := sh.input.ReadString('\n')
+			if strings.TrimSuffix(line, "\n") == redir.delimiter {
+				break
+			}
+			body.WriteString(line)
+			if err != nil {
+				return fmt.Errorf("heredoc: конец ввода до разделителя %q", redir.delimiter)
+			}
+		}
+		if redir.quoted {
+			redir.body = word{quoted: true, parts: []wordPart{{kind: partLiteral, text: body.String()}}}
+			continue
+		}
+		bodyWord, err := lexHeredocBody(body.String())
+		if err != nil {
+			return err
+		}
+		redir.body = bodyWord
+	}
+	return nil
+}
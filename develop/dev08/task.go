@@ -2,13 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/mitchellh/go-ps"
 )
@@ -22,160 +29,506 @@ import (
 поддержать fork/exec команды
 конвеер на пайпах
 
+Управление заданиями (jobs.go): cmd & запускает команду в фоне, jobs, fg %n, bg %n, wait, kill %n.
+Каждое задание - своя группа процессов, Ctrl+C и Ctrl+Z получает только задание переднего плана.
+О завершении фоновых заданий шелл сообщает перед следующим приглашением.
+
+Конвейер cmd1 | cmd2 | cmd3 соединяет stdout каждой команды со stdin следующей через os.Pipe.
+Встроенные команды тоже могут быть звеньями конвейера. Шелл ждет завершения всех команд,
+$? - код возврата последней команды конвейера.
+
+Строка разбирается лексером и парсером (parser.go): кавычки, экранирование, $VAR, ${VAR}, $?, ~,
+списки команд через ;, && и ||. Встроенные команды получают аргументы после всех подстановок.
+
+Перенаправления >, >>, <, 2>, 2>&1 и <<EOF (redirect.go) работают и для внешних, и для встроенных команд.
+
+Переменные (variables.go): NAME=значение, export, unset, env, set. Внешние команды получают
+окружение из экспортированных переменных шелла через exec.Cmd.Env, A=1 cmd задает A только для cmd.
+
 Реализовать утилиту netcat (nc) клиент
 принимать данные из stdin и отправлять в соединение (tcp/udp)
 Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 */
 
-func pwd() {
-	// pwd выводит путь до текущей директории
-	pwd, err := os.Getwd()
+// stdio - стандартные потоки команды
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+	err io.Writer
+}
+
+// shell - состояние шелла между командами
+type shell struct {
+	// status - код возврата последней команды, $?
+	status int
+	// input - откуда шелл читает команды и тела heredoc
+	input *bufio.Reader
+	// vars - переменные шелла, экспортированные из них - окружение запускаемых команд
+	vars variables
+	// dir - текущая директория шелла. Директорию процесса шелл не меняет: она общая для фоновых заданий
+	// и встроенных команд в конвейере, а у каждой копии шелла директория своя
+	dir string
+	// interactive - ввод с терминала: задания переднего плана получают терминал. pgid - группа самого шелла
+	interactive bool
+	pgid        int
+	// jobs - фоновые и остановленные задания, foreground - задание переднего плана
+	jobs       []*job
+	foreground atomic.Pointer[job]
+	// interrupts - Ctrl+C без задания переднего плана. Канал без буфера: сигнал получает только
+	// ждущий wait, а нажатие в другое время теряется. У копий шелла канала нет
+	interrupts chan struct{}
+}
+
+// builtin - встроенная команда. Возвращает код возврата
+type builtin func(sh *shell, args []string, std stdio) int
+
+var builtins map[string]builtin
+
+func init() {
+	// Заполняется в init, потому что встроенные команды ссылаются на шелл, а шелл - на builtins
+	builtins = map[string]builtin{
+		"cd":     cd,
+		"pwd":    pwd,
+		"echo":   echo,
+		"kill":   kill,
+		"ps":     psCommand,
+		":":      func(*shell, []string, stdio) int { return 0 },
+		"jobs":   jobsCommand,
+		"fg":     fg,
+		"bg":     bg,
+		"wait":   waitCommand,
+		"export": export,
+		"unset":  unset,
+		"env":    envCommand,
+		"set":    setCommand,
+	}
+}
+
+func newShell() *shell {
+	// newShell создает шелл с переменными из окружения процесса и его текущей директорией
+	dir, err := os.Getwd()
 	if err != nil {
-		log.Println("ошибка в команде pwd: ", err)
-		os.Exit(1)
+		log.Println(err)
 	}
-	fmt.Println(pwd)
+	return &shell{vars: environVariables(os.Environ()), dir: dir, interrupts: make(chan struct{})}
 }
 
-func cd(args []string) {
-	// cd Выполняет переход в указанную директорию
+func (sh *shell) path(name string) string {
+	// path возвращает путь name относительно текущей директории шелла
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(sh.dir, name)
+}
+
+func pwd(sh *shell, _ []string, std stdio) int {
+	// pwd выводит путь до текущей директории
+	fmt.Fprintln(std.out, sh.dir)
+	return 0
+}
+
+func cd(sh *shell, args []string, std stdio) int {
+	// cd Выполняет переход в указанную директорию. Меняется только директория этого шелла,
+	// запущенные команды получают ее через exec.Cmd.Dir
 	if len(args) < 2 {
-		log.Println("путь не указан")
-		return
+		fmt.Fprintln(std.err, "путь не указан")
+		return 1
+	}
+	dir := sh.path(args[1])
+	info, err := os.Stat(dir)
+	if err == nil && !info.IsDir() {
+		err = &fs.PathError{Op: "chdir", Path: args[1], Err: syscall.ENOTDIR}
 	}
-	// Chdir изменяет текущий рабочий каталог на каталог с именем. Если возникает ошибка, то она будет типа *PathError
-	err := os.Chdir(args[1])
 	if err != nil {
-		log.Println("ошибка в команде cd: ", err)
+		fmt.Fprintln(std.err, "ошибка в команде cd: ", err)
+		return 1
 	}
+	sh.vars.set("OLDPWD", sh.dir)
+	sh.vars.set("PWD", dir)
+	sh.dir = dir
+	return 0
 }
 
-func echo(args []string) {
+func echo(_ *shell, args []string, std stdio) int {
 	// echo используется для печати введенного текста в терминале
-	fmt.Println(strings.Join(args[1:], " "))
+	fmt.Fprintln(std.out, strings.Join(args[1:], " "))
+	return 0
 }
 
-func kill(args []string) {
-	// kill завершает процесс по его PID
+func kill(sh *shell, args []string, std stdio) int {
+	// kill завершает процесс по его PID или все процессы задания по %n
 	if len(args) < 2 {
-		fmt.Println("kill: PID не указан")
-		return
+		fmt.Fprintln(std.err, "kill: PID не указан")
+		return 1
+	}
+	if strings.HasPrefix(args[1], "%") {
+		j, err := sh.findJob(args[1])
+		if err == nil {
+			err = signalGroup(j.pgid(), os.Kill)
+		}
+		if err != nil {
+			fmt.Fprintln(std.err, "ошибка в команде kill: ", err)
+			return 1
+		}
+		return 0
 	}
 	pid, err := strconv.Atoi(args[1])
 	if err != nil {
-		log.Println("ошибка в команде kill: ", err)
-		return
+		fmt.Fprintln(std.err, "ошибка в команде kill: ", err)
+		return 1
 	}
 	process, err := os.FindProcess(pid)
 	if err != nil {
-		log.Println("ошибка в команде kill: ", err)
-		return
+		fmt.Fprintln(std.err, "ошибка в команде kill: ", err)
+		return 1
 	}
 	err = process.Kill()
 	if err != nil {
-		log.Println("ошибка в команде kill: ", err)
-		return
+		fmt.Fprintln(std.err, "ошибка в команде kill: ", err)
+		return 1
 	}
+	return 0
 }
 
-func psCommand(args []string) {
+func psCommand(_ *shell, args []string, std stdio) int {
 	// ps ввыводит список запущенных процессов
 	if len(args) != 1 {
-		log.Println("ps не ожидает дополнительных аргументов")
-		return
+		fmt.Fprintln(std.err, "ps не ожидает дополнительных аргументов")
+		return 1
 	}
 
 	proceslist, err := ps.Processes()
 	if err != nil {
-		log.Println("Ошибка в поиске запущенных процессов: ", err)
-		return
+		fmt.Fprintln(std.err, "Ошибка в поиске запущенных процессов: ", err)
+		return 1
 	}
 	for _, val := range proceslist {
-		fmt.Println("PID процесса: ", val.Pid(), " Название процесса: ", val.Executable())
+		fmt.Fprintln(std.out, "PID процесса: ", val.Pid(), " Название процесса: ", val.Executable())
+	}
+	return 0
+}
 
+func exitCode(err error) int {
+	// exitCode превращает ошибку запуска или ожидания процесса в код возврата, как в bash:
+	// 127 - команда не найдена, 126 - не удалось запустить, иначе код завершения процесса
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		// Процесс, завершенный сигналом, как в bash получает код 128 + номер сигнала
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	case errors.Is(err, exec.ErrNotFound):
+		return 127
 	}
+	return 126
 }
 
-func execCommand(args []string) {
-	// Выполнение внешней команды, основная горутина ожидает выполнения
+func newCommand(args []string, dir string, vars variables, std stdio) *exec.Cmd {
 	// exec.Command создает новый объект команды. args[0] содержит имя команды, которую нужно выполнить (например ls).
 	cmd := exec.Command(args[0], args[1:]...)
-	// Указывает, что стандартный вывод команды должен быть направлен на стандартный вывод текущего процесса (т.е. на консоль).
-	cmd.Stdout = os.Stdout
-	// Указывает, что стандартный вывод ошибок команды должен быть направлен на стандартный вывод ошибок текущего процесса.
-	cmd.Stderr = os.Stderr
-	// Указывает, что стандартный ввод команды должен быть направлен на стандартный ввод текущего процесса.
-	cmd.Stdin = os.Stdin
-	// cmd.Run() выполняет команду и ждет её завершения.
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Ошибка при выполнении команды: ", err)
-	}
+	// Команда ищется по PATH шелла и получает его экспортированные переменные и директорию,
+	// а не окружение и директорию процесса шелла
+	cmd.Path, cmd.Err = lookPath(args[0], vars.get("PATH"), dir)
+	cmd.Env = vars.environ()
+	cmd.Dir = dir
+	// Потоки берутся из std: для одиночной команды это потоки шелла (консоль), в конвейере - концы пайпов.
+	// Если поток - *os.File, процесс пишет и читает его напрямую, без промежуточных горутин
+	cmd.Stdin = std.in
+	cmd.Stdout = std.out
+	cmd.Stderr = std.err
+	return cmd
 }
 
-func forkExecCommand(args []string) {
-	// Создание нового процесса и выполнение команды, основная горутина не ждет выполнения.
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	err := cmd.Start() // Асинхронный запуск команды
-	if err != nil {
-		log.Println("Ошибка при выполнении команды: ", err)
-		return
+// stage - команда конвейера после подстановок
+type stage struct {
+	args      []string
+	redirects []*redirect
+	// assigned - переменные из присваиваний перед командой
+	assigned map[string]string
+	// external - запускать внешнюю команду, даже если есть встроенная с тем же именем (env cmd)
+	external bool
+}
+
+func (st stage) builtin() (builtin, bool) {
+	if st.external {
+		return nil, false
 	}
+	fn, ok := builtins[st.args[0]]
+	return fn, ok
+}
 
-	// Ожидание завершения команды в отдельной горутине
-	go func() {
-		err := cmd.Wait()
+func (sh *shell) startPipeline(stages []stage, std stdio, pg *processGroup) func() int {
+	// startPipeline запускает все команды конвейера одновременно, соединяя их через os.Pipe,
+	// и возвращает функцию, которая ждет завершения всех и возвращает код возврата последней.
+	// Внешние команды попадают в группу процессов pg
+	statuses := make([]int, len(stages))
+	var wg sync.WaitGroup
+	// started - запущенные процессы. Их ждут только после запуска всего конвейера: пока лидер группы
+	// не освобожден через Wait, его группа существует, и следующие процессы могут в нее войти
+	var started []*exec.Cmd
+	var startedIdx []int
+	// cleanup - концы пайпов, которые шелл должен закрыть у себя после запуска внешней команды:
+	// дочерний процесс получил свои копии дескрипторов, а пока открыт конец записи у шелла,
+	// читатель не получит EOF. Файлы перенаправлений закрываются после завершения всех команд
+	var cleanup, opened []io.Closer
+	in := std.in
+	for i, st := range stages {
+		stageStd := stdio{in: in, out: std.out, err: std.err}
+		var reader, writer *os.File
+		if i < len(stages)-1 {
+			var err error
+			reader, writer, err = os.Pipe()
+			if err != nil {
+				fmt.Fprintln(std.err, "ошибка создания пайпа: ", err)
+				statuses[len(statuses)-1] = 1
+				// Закрываем конец чтения, иначе предыдущая команда может навсегда заблокироваться на записи
+				if f, ok := in.(*os.File); ok && i > 0 {
+					f.Close()
+				}
+				break
+			}
+			stageStd.out = writer
+		}
+		// Конец чтения предыдущего пайпа принадлежит этой команде
+		var prevReader io.Closer
+		if f, ok := in.(*os.File); ok && i > 0 {
+			prevReader = f
+		}
+		closePipes := func() {
+			if writer != nil {
+				writer.Close()
+			}
+			if prevReader != nil {
+				prevReader.Close()
+			}
+		}
+		in = reader
+
+		// Перенаправления применяются после пайпов, поэтому "cmd > файл | cat" пишет в файл, а не в пайп
+		redirected, files, err := sh.applyRedirects(st.redirects, stageStd)
+		opened = append(opened, files...)
 		if err != nil {
-			log.Println("Ошибка при завершении команды: ", err)
+			fmt.Fprintln(stageStd.err, err)
+			statuses[i] = 1
+			closePipes()
+			continue
 		}
-	}()
+
+		if fn, ok := st.builtin(); ok {
+			wg.Add(1)
+			// Встроенная команда в конвейере выполняется на копии шелла, как в подоболочке bash:
+			// ее export, unset и cd не меняют переменные и директорию шелла
+			stageShell := sh.subshell()
+			stageShell.vars = sh.vars.with(st.assigned)
+			stageShell.jobs = slices.Clone(sh.jobs)
+			go func(i int, args []string) {
+				defer wg.Done()
+				statuses[i] = fn(stageShell, args, redirected)
+				// Встроенная команда закончила писать: закрываем пайп, чтобы следующая получила EOF.
+				// Закрытие конца чтения дает предыдущей команде EPIPE, если она еще пишет
+				closePipes()
+			}(i, st.args)
+			continue
+		}
+		cmd := newCommand(st.args, sh.dir, sh.vars.with(st.assigned), redirected)
+		setProcessGroup(cmd, pg)
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintln(redirected.err, st.args[0]+":", err)
+			statuses[i] = exitCode(err)
+		} else {
+			if pg.id() == 0 {
+				pg.setID(cmd.Process.Pid)
+			}
+			started = append(started, cmd)
+			startedIdx = append(startedIdx, i)
+		}
+		if writer != nil {
+			cleanup = append(cleanup, writer)
+		}
+		if prevReader != nil {
+			cleanup = append(cleanup, prevReader)
+		}
+	}
+	for _, c := range cleanup {
+		c.Close()
+	}
+	for n, cmd := range started {
+		wg.Add(1)
+		go func(i int, cmd *exec.Cmd) {
+			defer wg.Done()
+			statuses[i] = exitCode(cmd.Wait())
+		}(startedIdx[n], cmd)
+	}
+	return func() int {
+		wg.Wait()
+		closeAll(opened)
+		return statuses[len(statuses)-1]
+	}
 }
 
-func handleCommand(command string) {
-	// В зависимости от введенной команды вызывает функцию ее выполнения.
-	// Разбиваем прочитанную строку в слайс по пробелам
-	args := strings.Fields(command)
+func (sh *shell) lookup(name string) string {
+	// lookup возвращает значение переменной шелла или пустую строку
+	return sh.vars.get(name)
+}
 
-	if len(args) == 0 {
-		return
+func (sh *shell) expandWord(w word) (string, bool) {
+	// expandWord выполняет подстановки в слове. Второе значение - оставлять ли слово аргументом:
+	// слово без кавычек, которое стало пустым, как $UNSET, выбрасывается
+	var result strings.Builder
+	for _, part := range w.parts {
+		switch part.kind {
+		case partLiteral:
+			result.WriteString(part.text)
+		case partVar:
+			result.WriteString(sh.lookup(part.text))
+		case partStatus:
+			result.WriteString(strconv.Itoa(sh.status))
+		case partHome:
+			result.WriteString(sh.lookup("HOME"))
+		}
 	}
+	return result.String(), w.quoted || result.Len() > 0
+}
 
-	switch args[0] {
-	case "cd":
-		cd(args)
-	case "pwd":
-		pwd()
-	case "echo":
-		echo(args)
-	case "kill":
-		kill(args)
-	case "ps":
-		psCommand(args)
-	case "fork-exec":
-		forkExecCommand(args)
-	default:
-		execCommand(args)
+func (sh *shell) expandArgs(words []word) []string {
+	args := make([]string, 0, len(words))
+	for _, w := range words {
+		if arg, ok := sh.expandWord(w); ok {
+			args = append(args, arg)
+		}
 	}
+	return args
 }
 
-func main() {
-	// Читаем данные с консоли
-	reader := bufio.NewReader(os.Stdin)
+func (sh *shell) execPipeline(p *pipeline, std stdio, background *job) int {
+	// execPipeline раскрывает слова команд и запускает конвейер. Подстановки выполняются
+	// непосредственно перед запуском, чтобы $? видел код предыдущего конвейера.
+	// background - фоновое задание, в котором выполняется конвейер, или nil для переднего плана
+	stages := make([]stage, 0, len(p.commands))
+	for _, cmd := range p.commands {
+		var assigned map[string]string
+		switch {
+		case len(cmd.args) > 0:
+			assigned = sh.assign(cmd.assignments)
+		case len(p.commands) == 1:
+			// NAME=значение без команды задает переменные самого шелла, по очереди: A=1 B=$A дает B=1.
+			// В конвейере из нескольких команд такое присваивание, как в bash, ни на что не влияет
+			for _, a := range cmd.assignments {
+				value, _ := sh.expandWord(a.value)
+				sh.vars.set(a.name, value)
+			}
+		}
+		args := sh.expandArgs(cmd.args)
+		if len(args) == 0 {
+			// Нет слов или все оказались пустыми подстановками: выполняются только перенаправления
+			args = []string{":"}
+		}
+		stages = append(stages, envStage(stage{args: args, redirects: cmd.redirects, assigned: assigned}))
+	}
+
+	// Одиночная встроенная команда выполняется в самом шелле, чтобы cd менял его директорию
+	if len(stages) == 1 {
+		if fn, ok := stages[0].builtin(); ok {
+			redirected, files, err := sh.applyRedirects(stages[0].redirects, std)
+			defer closeAll(files)
+			if err != nil {
+				fmt.Fprintln(std.err, err)
+				return 1
+			}
+			return sh.runWithAssignments(fn, stages[0].args, stages[0].assigned, redirected)
+		}
+	}
+	if background != nil {
+		pg := &processGroup{}
+		background.group.Store(pg)
+		wait := sh.startPipeline(stages, std, pg)
+		background.markStarted()
+		return wait()
+	}
+	// Конвейер переднего плана - отдельное задание: по Ctrl+Z оно попадает в таблицу заданий
+	pg := &processGroup{foreground: sh.interactive}
+	j := startJob(p.String(), pg, sh.startPipeline(stages, std, pg))
+	return sh.waitForeground(j, std)
+}
+
+func (sh *shell) execAndOr(item *andOr, std stdio, background *job) {
+	// execAndOr выполняет конвейеры по очереди. После && следующий конвейер запускается,
+	// только если предыдущий завершился успешно, после || - только если с ошибкой
+	sh.status = sh.execPipeline(item.pipelines[0], std, background)
+	for i, op := range item.ops {
+		if (op == tokenAnd) != (sh.status == 0) {
+			continue
+		}
+		sh.status = sh.execPipeline(item.pipelines[i+1], std, background)
+	}
+}
+
+func (sh *shell) execList(list *commandList, std stdio) {
+	// execList выполняет команды списка по очереди, а команды с & запускает в фоне
+	for _, item := range list.items {
+		if item.background {
+			sh.startBackground(item, std)
+			continue
+		}
+		sh.execAndOr(item, std, nil)
+	}
+}
+
+func (sh *shell) handleCommand(command string, std stdio) {
+	// handleCommand разбирает строку и выполняет получившийся список команд
+	list, err := parse(command)
+	if err == nil {
+		err = sh.readHeredocs(list.heredocs)
+	}
+	if err != nil {
+		fmt.Fprintln(std.err, err)
+		sh.status = 2
+		return
+	}
+	sh.execList(list, std)
+}
+
+func (sh *shell) run(r io.Reader, std stdio, prompt string) {
+	// run читает команды из r построчно и выполняет их. Следующие строки могут оказаться телом heredoc,
+	// поэтому шелл читает их через тот же sh.input
+	sh.input = bufio.NewReader(r)
 	for {
-		fmt.Print("Введите комманду > ")
-		command, err := reader.ReadString('\n')
+		// Перед приглашением сообщаем о завершившихся фоновых заданиях
+		sh.reportJobs(std.err)
+		fmt.Fprint(std.out, prompt)
+		command, err := sh.input.ReadString('\n')
 		// err != nil тогда и только тогда, когда возвращаемые данные не заканчиваются на разделитель
+		if command != "" {
+			sh.handleCommand(strings.TrimSpace(command), std)
+		}
+		if err == io.EOF {
+			return
+		}
 		if err != nil {
-			// Если ошибка во время чтения, то программа завершается с кодом 1
-			if err == io.EOF {
-				os.Exit(1)
-			}
 			log.Println(err)
+			return
 		}
-		command = strings.TrimSpace(command)
-		handleCommand(command)
 	}
 }
+
+func main() {
+	sh := newShell()
+	sh.interactive = isTerminal(os.Stdin)
+	sh.pgid = shellGroup()
+	std := stdio{in: os.Stdin, out: os.Stdout, err: os.Stderr}
+	// Ctrl+C и Ctrl+Z не должны завершать и останавливать сам шелл
+	signals := make(chan os.Signal, 1)
+	notifyJobSignals(signals)
+	go func() {
+		for sig := range signals {
+			sh.forwardSignal(sig)
+		}
+	}()
+	// Читаем данные с консоли. По концу ввода программа завершается с кодом 1
+	sh.run(os.Stdin, std, "Введите комманду > ")
+	os.Exit(1)
+}
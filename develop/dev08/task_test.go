@@ -1 +1,288 @@
 package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer - буфер для вывода шелла: в него одновременно пишут команды конвейера и фоновые задания
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// runShell выполняет строки как ввод шелла и возвращает stdout, stderr и $? последней команды
+func runShell(t *testing.T, lines ...string) (string, string, int) {
+	t.Helper()
+	// Фоновые задания пишут в оба потока из своих горутин
+	var out, errOut lockedBuffer
+	sh := newShell()
+	std := stdio{in: strings.NewReader(""), out: &out, err: &errOut}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sh.run(strings.NewReader(strings.Join(lines, "\n")+"\n"), std, "")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("команды %q не завершились за 5 секунд", lines)
+	}
+	return out.String(), errOut.String(), sh.status
+}
+
+func TestPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+		status   int
+	}{
+		{name: "встроенная команда", lines: []string{"echo hello"}, expected: "hello\n"},
+		{name: "встроенная и внешняя", lines: []string{"echo hello | cat"}, expected: "hello\n"},
+		{name: "три звена", lines: []string{"echo a b | tr a-z A-Z | cat"}, expected: "A B\n"},
+		{name: "встроенная в конце", lines: []string{"cat /dev/null | echo x"}, expected: "x\n"},
+		{name: "ps в конвейере", lines: []string{"ps | head -n 1 | cut -d : -f 1"}, expected: "PID процесса\n"},
+		{name: "код последней команды", lines: []string{"true | false"}, status: 1},
+		{name: "код не первой команды", lines: []string{"false | true"}, status: 0},
+		{name: "подстановка $?", lines: []string{"true | false", "echo $?"}, expected: "1\n"},
+		{name: "команда не найдена", lines: []string{"echo x | no-such-command-dev08"}, status: 127},
+		{name: "читатель завершился раньше писателя", lines: []string{"yes | head -n 2"}, expected: "y\ny\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, _, status := runShell(t, test.lines...)
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, out)
+			}
+			if status != test.status {
+				t.Errorf("Ожидалось: $?=%d, получено: %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestPipelineSyntaxError(t *testing.T) {
+	for _, line := range []string{"echo x |", "| cat", "echo x | | cat", "echo x &&", "; echo x", "echo 'x"} {
+		_, errOut, status := runShell(t, line)
+		if status != 2 || errOut == "" {
+			t.Errorf("%q: ожидалась синтаксическая ошибка, получено: $?=%d, %q", line, status, errOut)
+		}
+	}
+}
+
+func TestLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+		status   int
+	}{
+		{name: "точка с запятой", line: "echo a; echo b", expected: "a\nb\n"},
+		{name: "&& после успеха", line: "true && echo да", expected: "да\n"},
+		{name: "&& после ошибки", line: "false && echo да", status: 1},
+		{name: "|| после ошибки", line: "false || echo нет", expected: "нет\n"},
+		{name: "|| после успеха", line: "true || echo нет"},
+		{name: "цепочка слева направо", line: "true || false && echo x", expected: "x\n"},
+		{name: "цепочка с ошибкой", line: "false || false && echo x", status: 1},
+		{name: "$? внутри списка", line: "false; echo $?; true; echo $?", expected: "1\n0\n"},
+		{name: "конвейер в списке", line: "echo abc | tr a-c x-z && echo ok", expected: "xyz\nok\n"},
+		{name: "кавычки", line: `echo "hello   world" 'a  b' c\ \ d`, expected: "hello   world a  b c  d\n"},
+		{name: "| в кавычках", line: `echo 'a | b' "c;d"`, expected: "a | b c;d\n"},
+		{name: "комментарий", line: "echo a # echo b", expected: "a\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, _, status := runShell(t, test.line)
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q", test.expected, out)
+			}
+			if status != test.status {
+				t.Errorf("Ожидалось: $?=%d, получено: %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestExpansion(t *testing.T) {
+	t.Setenv("DEV08_NAME", "мир")
+	t.Setenv("HOME", "/home/test")
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "echo $DEV08_NAME", expected: "мир\n"},
+		{line: "echo ${DEV08_NAME}!", expected: "мир!\n"},
+		{line: `echo "привет, $DEV08_NAME"`, expected: "привет, мир\n"},
+		{line: `echo '$DEV08_NAME'`, expected: "$DEV08_NAME\n"},
+		{line: `echo \$DEV08_NAME "\$x"`, expected: "$DEV08_NAME $x\n"},
+		{line: "echo ~ ~/dir a~", expected: "/home/test /home/test/dir a~\n"},
+		{line: `echo "~"`, expected: "~\n"},
+		{line: "echo a $DEV08_UNSET b", expected: "a b\n"},
+		{line: `echo a "$DEV08_UNSET" b`, expected: "a  b\n"},
+		{line: "echo $ 5$", expected: "$ 5$\n"},
+		{line: "$DEV08_UNSET", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			out, errOut, _ := runShell(t, test.line)
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q (%s)", test.expected, out, errOut)
+			}
+		})
+	}
+}
+
+func TestVariables(t *testing.T) {
+	t.Setenv("DEV08_EXPORTED", "окружение")
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+		status   int
+	}{
+		{name: "локальная переменная", lines: []string{"A=мир", `echo "привет, $A"`}, expected: "привет, мир\n"},
+		{name: "присваивания по очереди", lines: []string{"A=1 B=$A", "echo $B"}, expected: "1\n"},
+		{name: "локальная не в окружении", lines: []string{"A=1", "sh -c 'echo [$A]'"}, expected: "[]\n"},
+		{name: "export", lines: []string{"A=1", "export A B=2", "sh -c 'echo $A $B'"}, expected: "1 2\n"},
+		{name: "окружение процесса", lines: []string{"sh -c 'echo $DEV08_EXPORTED'"}, expected: "окружение\n"},
+		{name: "присваивание для команды", lines: []string{"A=1 sh -c 'echo $A'", "echo [$A]"}, expected: "1\n[]\n"},
+		{name: "для встроенной команды", lines: []string{"A=2 env | grep ^A=", "echo [$A]"}, expected: "A=2\n[]\n"},
+		{name: "временно для встроенной", lines: []string{"A=1", "A=2 export B=3", "echo $A $B", "env | grep -c ^A="}, expected: "1 3\n0\n", status: 1},
+		{name: "unset", lines: []string{"unset DEV08_EXPORTED", "echo [$DEV08_EXPORTED]", "env | grep -c DEV08"}, expected: "[]\n0\n", status: 1},
+		{name: "env с командой", lines: []string{"env A=1 sh -c 'echo $A'"}, expected: "1\n"},
+		{name: "env с присваиванием перед ним", lines: []string{"A=1 B=1 env A=2 sh -c 'echo $A $B'"}, expected: "2 1\n"},
+		{name: "env запускает только внешние команды", lines: []string{"env jobs"}, status: 127},
+		{name: "env без команды", lines: []string{"env A=1 | grep ^A="}, expected: "A=1\n"},
+		{name: "set", lines: []string{`DEV08_LOCAL="it's"`, "set | grep DEV08_LOCAL"}, expected: `DEV08_LOCAL='it'\''s'` + "\n"},
+		{name: "export без аргументов", lines: []string{"export | grep DEV08_EXPORTED"}, expected: "export DEV08_EXPORTED='окружение'\n"},
+		{name: "export в конвейере", lines: []string{"export A=1 | cat", "echo [$A]"}, expected: "[]\n"},
+		{name: "PATH шелла", lines: []string{"PATH=/nonexistent", "ls"}, status: 127},
+		{name: "неверное имя", lines: []string{"export 1A=2"}, status: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, errOut, status := runShell(t, test.lines...)
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q (%s)", test.expected, out, errOut)
+			}
+			if status != test.status {
+				t.Errorf("Ожидался код %d, получен %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestCd(t *testing.T) {
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	os.WriteFile(filepath.Join(dir, "file.txt"), nil, 0o644)
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+		status   int
+	}{
+		{name: "cd меняет директорию шелла", lines: []string{"cd " + dir, "pwd"}, expected: dir + "\n"},
+		{name: "относительный путь", lines: []string{"cd " + dir, "cd sub", "pwd", "cd ..", "pwd"}, expected: dir + "/sub\n" + dir + "\n"},
+		{name: "внешние команды в директории шелла", lines: []string{"cd " + dir, "ls", "sh -c pwd"}, expected: "file.txt\nsub\n" + dir + "\n"},
+		{name: "перенаправление относительно директории шелла", lines: []string{"cd " + dir + "/sub", "echo x > out.txt", "cat " + dir + "/sub/out.txt"}, expected: "x\n"},
+		{name: "PWD и OLDPWD", lines: []string{"cd " + dir, "cd sub", "echo $PWD $OLDPWD"}, expected: dir + "/sub " + dir + "\n"},
+		{name: "cd в конвейере не меняет директорию шелла", lines: []string{"cd / | cat", "pwd"}, expected: wd + "\n"},
+		{name: "не директория", lines: []string{"cd " + dir + "/file.txt", "pwd"}, expected: wd + "\n"},
+		{name: "нет директории", lines: []string{"cd " + dir + "/нет"}, status: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, errOut, status := runShell(t, test.lines...)
+			if out != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q (%s)", test.expected, out, errOut)
+			}
+			if status != test.status {
+				t.Errorf("Ожидался код %d, получен %d", test.status, status)
+			}
+		})
+	}
+	// Директория процесса не меняется
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("Директория процесса изменилась: %s", now)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+	os.WriteFile("input.txt", []byte("строка 1\nстрока 2\n"), 0o644)
+	t.Setenv("DEV08_NAME", "мир")
+
+	tests := []struct {
+		name     string
+		lines    []string
+		expected string
+		errOut   string
+		status   int
+	}{
+		{name: "> и <", lines: []string{"echo привет > out.txt", "cat < out.txt"}, expected: "привет\n"},
+		{name: "> перезаписывает", lines: []string{"echo 1 > out.txt", "echo 2 > out.txt", "cat out.txt"}, expected: "2\n"},
+		{name: ">> дописывает", lines: []string{"echo 1 > out.txt", "echo 2 >> out.txt", "cat out.txt"}, expected: "1\n2\n"},
+		{name: "ввод внешней команды", lines: []string{"wc -l < input.txt"}, expected: "2\n"},
+		{name: "2> для внешней команды", lines: []string{"ls no-such-file 2> err.txt", "wc -l < err.txt"}, expected: "1\n", status: 0},
+		{name: "2>&1 в конвейере", lines: []string{"ls no-such-file 2>&1 | wc -l"}, expected: "1\n"},
+		{name: "порядок: > файл 2>&1", lines: []string{"ls no-such-file > out.txt 2>&1", "wc -l < out.txt"}, expected: "1\n"},
+		{name: "порядок: 2>&1 > файл", lines: []string{"ls no-such-file 2>&1 > out.txt | wc -l"}, expected: "1\n"},
+		{name: "встроенная команда в файл", lines: []string{"pwd > out.txt", "cat out.txt"}, expected: dir + "\n"},
+		{name: "ошибка встроенной команды в 2>", lines: []string{"cd 2> err.txt", "cat err.txt"}, expected: "путь не указан\n"},
+		{name: "встроенная команда >&2", lines: []string{"echo ошибка >&2"}, errOut: "ошибка\n"},
+		{name: "перенаправление в середине конвейера", lines: []string{"echo a > out.txt | cat", "cat out.txt"}, expected: "a\n"},
+		{name: "только перенаправление", lines: []string{"> empty.txt", "wc -c < empty.txt"}, expected: "0\n"},
+		{name: "имя файла из переменной", lines: []string{"echo x > $DEV08_NAME.txt", "cat мир.txt"}, expected: "x\n"},
+		{name: "2 с пробелом - аргумент", lines: []string{"echo 2 > out.txt", "cat out.txt"}, expected: "2\n"},
+		{name: "нет файла", lines: []string{"cat < no-such-file"}, status: 1},
+		{name: "0> не создает файл", lines: []string{"echo x 0> created.txt", "cat created.txt"}, status: 1},
+		{name: "1< не обрезает файл", lines: []string{"echo важное > keep.txt", "echo x 1< keep.txt", "cat keep.txt"}, expected: "важное\n"},
+		{name: "heredoc", lines: []string{"cat <<EOF", "привет, $DEV08_NAME", "  $? \\$x", "EOF"}, expected: "привет, мир\n  0 $x\n"},
+		{name: "heredoc в кавычках", lines: []string{"cat <<'EOF'", "привет, $DEV08_NAME", "EOF"}, expected: "привет, $DEV08_NAME\n"},
+		{name: "heredoc в конвейере", lines: []string{"cat <<EOF | wc -l", "a", "b", "EOF", "echo дальше"}, expected: "2\nдальше\n"},
+		{name: "два heredoc", lines: []string{"cat <<A; cat <<B", "1", "A", "2", "B"}, expected: "1\n2\n"},
+		{name: "незавершенный heredoc", lines: []string{"cat <<EOF", "a"}, status: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, errOut, status := runShell(t, test.lines...)
+			if strings.TrimLeft(out, " ") != test.expected {
+				t.Errorf("Ожидалось: %q, получено: %q (%s)", test.expected, out, errOut)
+			}
+			if test.errOut != "" && errOut != test.errOut {
+				t.Errorf("Ожидалось в stderr: %q, получено: %q", test.errOut, errOut)
+			}
+			if status != test.status {
+				t.Errorf("Ожидалось: $?=%d, получено: %d", test.status, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Переменные шелла.
+
+Шелл хранит все переменные в своей таблице, а не в окружении процесса: при запуске берется
+os.Environ(), дальше окружение процесса шелла не меняется. Экспортированные переменные
+передаются запускаемым командам через exec.Cmd.Env, остальные видны только в подстановках.
+
+NAME=значение без команды задает переменную шелла, перед командой - переменную окружения
+только этой команды. В конвейере из нескольких команд присваивания и export во встроенных
+командах не меняют переменные шелла, как в подоболочке bash.
+*/
+
+// variable - значение переменной и передается ли она запускаемым командам
+type variable struct {
+	value    string
+	exported bool
+}
+
+// variables - таблица переменных шелла по имени
+type variables map[string]variable
+
+func environVariables(environ []string) variables {
+	// environVariables строит таблицу из окружения вида NAME=значение. Все переменные окружения экспортированы
+	vars := make(variables, len(environ))
+	for _, kv := range environ {
+		if name, value, ok := strings.Cut(kv, "="); ok && name != "" {
+			vars[name] = variable{value: value, exported: true}
+		}
+	}
+	return vars
+}
+
+func (v variables) get(name string) string {
+	return v[name].value
+}
+
+func (v variables) set(name, value string) {
+	// set меняет значение, сохраняя признак экспорта
+	v[name] = variable{value: value, exported: v[name].exported}
+}
+
+func (v variables) clone() variables {
+	result := make(variables, len(v))
+	for name, val := range v {
+		result[name] = val
+	}
+	return result
+}
+
+func (v variables) with(assigned map[string]string) variables {
+	// with возвращает копию переменных для команды с присваиваниями перед ней: они экспортируются только в нее
+	result := v.clone()
+	for name, value := range assigned {
+		result[name] = variable{value: value, exported: true}
+	}
+	return result
+}
+
+func (v variables) names(exportedOnly bool) []string {
+	names := make([]string, 0, len(v))
+	for name, val := range v {
+		if val.exported || !exportedOnly {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (v variables) environ() []string {
+	// environ возвращает окружение для exec.Cmd.Env: экспортированные переменные в виде NAME=значение
+	names := v.names(true)
+	environ := make([]string, len(names))
+	for i, name := range names {
+		environ[i] = name + "=" + v[name].value
+	}
+	return environ
+}
+
+func lookPath(name, path, cwd string) (string, error) {
+	// lookPath ищет исполняемый файл в каталогах PATH шелла. exec.LookPath смотрел бы PATH процесса,
+	// а он не меняется, когда в шелле присваивают PATH. Относительные пути считаются от cwd -
+	// директории шелла, exec.Cmd разрешает их так же относительно Cmd.Dir
+	if strings.Contains(name, "/") {
+		return name, nil
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			// Пустой элемент PATH - текущая директория
+			dir = "."
+		}
+		file := dir + "/" + name
+		full := file
+		if !filepath.IsAbs(full) {
+			full = filepath.Join(cwd, full)
+		}
+		if info, err := os.Stat(full); err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			return file, nil
+		}
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func shellQuote(s string) string {
+	// shellQuote заключает значение в одинарные кавычки, чтобы вывод set и export можно было снова выполнить
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (sh *shell) assign(assignments []assignment) map[string]string {
+	// assign вычисляет значения присваиваний перед командой
+	assigned := make(map[string]string, len(assignments))
+	for _, a := range assignments {
+		assigned[a.name], _ = sh.expandWord(a.value)
+	}
+	return assigned
+}
+
+func (sh *shell) runWithAssignments(fn builtin, args []string, assigned map[string]string, std stdio) int {
+	// runWithAssignments выполняет встроенную команду в самом шелле с временными переменными
+	// из присваиваний перед ней. Остальные изменения переменных, например export, сохраняются
+	saved := make(variables, len(assigned))
+	for name := range assigned {
+		if val, ok := sh.vars[name]; ok {
+			saved[name] = val
+		}
+	}
+	for name, value := range assigned {
+		sh.vars[name] = variable{value: value, exported: true}
+	}
+	defer func() {
+		for name := range assigned {
+			if val, ok := saved[name]; ok {
+				sh.vars[name] = val
+			} else {
+				delete(sh.vars, name)
+			}
+		}
+	}()
+	return fn(sh, args, std)
+}
+
+func export(sh *shell, args []string, std stdio) int {
+	// export помечает переменные для передачи запускаемым командам. Без аргументов выводит экспортированные
+	if len(args) == 1 {
+		for _, name := range sh.vars.names(true) {
+			fmt.Fprintf(std.out, "export %s=%s\n", name, shellQuote(sh.vars.get(name)))
+		}
+		return 0
+	}
+	status := 0
+	for _, arg := range args[1:] {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !validName(name) {
+			fmt.Fprintf(std.err, "export: %q: неверное имя переменной\n", name)
+			status = 1
+			continue
+		}
+		if !hasValue {
+			value = sh.vars.get(name)
+		}
+		sh.vars[name] = variable{value: value, exported: true}
+	}
+	return status
+}
+
+func unset(sh *shell, args []string, std stdio) int {
+	// unset удаляет переменные шелла
+	status := 0
+	for _, name := range args[1:] {
+		if !validName(name) {
+			fmt.Fprintf(std.err, "unset: %q: неверное имя переменной\n", name)
+			status = 1
+			continue
+		}
+		delete(sh.vars, name)
+	}
+	return status
+}
+
+func envArgs(args []string) (map[string]string, []string) {
+	// envArgs делит аргументы env на присваивания NAME=значение и команду после них
+	assigned := map[string]string{}
+	i := 1
+	for ; i < len(args); i++ {
+		name, value, ok := strings.Cut(args[i], "=")
+		if !ok || !validName(name) {
+			break
+		}
+		assigned[name] = value
+	}
+	return assigned, args[i:]
+}
+
+func envStage(st stage) stage {
+	// envStage превращает "env NAME=значение... cmd" в запуск cmd с этими переменными. Как у env(1),
+	// cmd - всегда внешняя команда, и она запускается как любая другая: в группе процессов задания
+	if st.args[0] != "env" {
+		return st
+	}
+	assigned, command := envArgs(st.args)
+	if len(command) == 0 {
+		return st
+	}
+	for name, value := range st.assigned {
+		if _, ok := assigned[name]; !ok {
+			assigned[name] = value
+		}
+	}
+	return stage{args: command, redirects: st.redirects, assigned: assigned, external: true}
+}
+
+func envCommand(sh *shell, args []string, std stdio) int {
+	// env выводит окружение, которое получают команды, с переменными из аргументов NAME=значение.
+	// env с командой выполняется не здесь: envStage заменяет его запуском самой команды
+	assigned, command := envArgs(args)
+	if len(command) > 0 {
+		fmt.Fprintln(std.err, "env: команда не запущена:", command[0])
+		return 126
+	}
+	for _, kv := range sh.vars.with(assigned).environ() {
+		fmt.Fprintln(std.out, kv)
+	}
+	return 0
+}
+
+func setCommand(sh *shell, args []string, std stdio) int {
+	// set выводит все переменные шелла, и экспортированные, и локальные
+	if len(args) > 1 {
+		fmt.Fprintln(std.err, "set: параметры не поддерживаются")
+		return 2
+	}
+	for _, name := range sh.vars.names(false) {
+		fmt.Fprintf(std.out, "%s=%s\n", name, shellQuote(sh.vars.get(name)))
+	}
+	return 0
+}